@@ -1,9 +1,11 @@
 package buffer
 
 import (
+	"errors"
 	"io/ioutil"
 	"log"
 	"os"
+	"sort"
 )
 
 type Buffer struct {
@@ -19,13 +21,17 @@ const (
 )
 
 type Table struct {
-	Content string
-	Add     string
-	Mods    []*Piece
+	Content []rune
+	Add     []rune
+	Mods    PieceTree
+	hist    history
+	newline [2][]int // offsets of '\n' in Content and Add
 }
 
 // Mods ends up being a series of 'runs' which contain the current
-// value of the document
+// value of the document.
+// All offsets (Start, Run, and the indexes taken by Table methods)
+// count runes, not bytes, so multi-byte UTF-8 text is never split.
 
 type Piece struct {
 	Source PieceSource
@@ -35,8 +41,7 @@ type Piece struct {
 
 func NewBuffer(c string) *Buffer {
 	b := &Buffer{}
-	b.T = &Table{Content: c, Add: "", Mods: []*Piece{}}
-	b.T.Mods = append(b.T.Mods, NewPiece(Content, 0, len(c)))
+	b.T = NewTable(c)
 	b.Point = 0
 	b.Mark = 0
 	return b
@@ -48,8 +53,14 @@ func (buf *Buffer) AddRune(r rune) {
 }
 
 func NewTable(c string) *Table {
-	t := &Table{Content: c, Add: "", Mods: []*Piece{}}
-	t.Mods = append(t.Mods, NewPiece(Content, 0, len(c)))
+	rc := []rune(c)
+	t := &Table{Content: rc, Add: []rune{}}
+	for i, r := range rc {
+		if r == '\n' {
+			t.newline[Content] = append(t.newline[Content], i)
+		}
+	}
+	t.appendPiece(NewPiece(Content, 0, len(rc)))
 	return t
 }
 
@@ -58,14 +69,15 @@ func NewPiece(s PieceSource, pt, r int) *Piece {
 }
 
 func (t *Table) Size() int {
-	i := 0
-	for _, n := range t.Mods {
-		i += n.Run
-	}
-	return i
+	return t.Mods.Size()
+}
+
+// Lines returns the number of newlines in the document
+func (t *Table) Lines() int {
+	return t.Mods.Lines()
 }
 
-func (t *Table) source(ps PieceSource) string {
+func (t *Table) source(ps PieceSource) []rune {
 	if ps == Content {
 		return t.Content
 	} else {
@@ -73,96 +85,200 @@ func (t *Table) source(ps PieceSource) string {
 	}
 }
 
+// addRunes appends rs to the Add buffer, returning where they start
+func (t *Table) addRunes(rs []rune) int {
+	start := len(t.Add)
+	for i, r := range rs {
+		if r == '\n' {
+			t.newline[Add] = append(t.newline[Add], start+i)
+		}
+	}
+	t.Add = append(t.Add, rs...)
+	return start
+}
+
+// newlines counts the '\n' in source ps between [from, to)
+func (t *Table) newlines(ps PieceSource, from, to int) int {
+	nl := t.newline[ps]
+	return sort.SearchInts(nl, to) - sort.SearchInts(nl, from)
+}
+
+func (t *Table) pieceNewlines(p *Piece) int {
+	return t.newlines(p.Source, p.Start, p.Start+p.Run)
+}
+
 func (t *Table) RunForMod(index int) string {
-	p := t.Mods[index]
+	return string(t.runForMod(index))
+}
+
+func (t *Table) runForMod(index int) []rune {
+	return t.run(t.Mods.At(index))
+}
+
+func (t *Table) run(p *Piece) []rune {
 	return t.source(p.Source)[p.Start : p.Start+p.Run]
 }
 
 func (t *Table) head(p *Piece, idx int) string {
-	return t.source(p.Source)[:idx]
+	return string(t.source(p.Source)[:idx])
 }
 func (t *Table) tail(p *Piece, idx int) string {
-	return t.source(p.Source)[idx:]
+	return string(t.source(p.Source)[idx:])
 }
 
 func (t *Table) AllContents() string {
 	//return t.contents(0, t.size())
-	// need golang's stringbuilder
-	s := ""
+	s := make([]rune, 0, t.Size())
 
 	//t.dump()
-	for i := 0; i < len(t.Mods); i++ {
-		s += t.RunForMod(i)
-	}
+	t.Mods.root.each(func(p *Piece) {
+		s = append(s, t.run(p)...)
+	})
 	//log.Println("ac: ", s)
 	return string(s)
 }
 
-// need to add observance of start, end
+// Contents returns the text in rune offsets [start, end)
 func (t *Table) Contents(start, end int) string {
-	// need golang's stringbuilder
-	s := ""
-	si, ss := t.pieceAt(start)
-	sp := t.Mods[si]
-	ei, ee := t.pieceAt(end)
-	ep := t.Mods[ei]
-	startFrag := t.head(sp, ss)
-	endFrag := t.tail(ep, ee)
-	s += startFrag
-	for i := si + 1; i < ei; i++ {
-		s += t.RunForMod(i)
+	if start < 0 {
+		start = 0
+	}
+	if size := t.Size(); end > size {
+		end = size
+	}
+	if start >= end {
+		return ""
 	}
-	s += endFrag
-	return s
+	s := make([]rune, 0, end-start)
+	j, lo := t.pieceHolding(start)
+	for len(s) < end-start {
+		p := t.Mods.At(j)
+		hi := p.Run
+		if want := lo + end - start - len(s); want < hi {
+			hi = want
+		}
+		s = append(s, t.run(p)[lo:hi]...)
+		lo = 0
+		j++
+	}
+	return string(s)
+}
+
+// Pieces returns (copies of) the pieces of the document in order
+func (t *Table) Pieces() []*Piece {
+	return t.Mods.Pieces()
+}
+
+// IndexOf returns the rune at rune offset idx, 0 <= idx < Size(); it
+// panics for any other
+func (t *Table) IndexOf(idx int) rune {
+	e, i := t.pieceHolding(idx)
+	return t.runForMod(e)[i]
 }
 
-func (t *Table) IndexOf(idx int) byte {
-	e, i := t.pieceAt(idx)
-	return t.RunForMod(e)[i]
+// LineStart returns the offset of the first rune of line ln (origin = 0),
+// or Size() if there are not that many lines.
+func (t *Table) LineStart(ln int) int {
+	if ln <= 0 {
+		return 0
+	}
+	if ln > t.Lines() {
+		return t.Size()
+	}
+	n, off := t.Mods.root, 0
+	for n != nil {
+		if ln <= n.left.l() {
+			n = n.left
+			continue
+		}
+		ln -= n.left.l()
+		off += n.left.s()
+		if ln <= n.nl {
+			nl := t.newline[n.p.Source]
+			k := sort.SearchInts(nl, n.p.Start) + ln - 1
+			return off + nl[k] - n.p.Start + 1
+		}
+		ln -= n.nl
+		off += n.p.Run
+		n = n.right
+	}
+	return t.Size()
+}
+
+// LineFor returns the line (origin = 0) holding rune offset idx,
+// which is the count of newlines before it.
+func (t *Table) LineFor(idx int) int {
+	n, ln := t.Mods.root, 0
+	for n != nil {
+		if idx <= n.left.s() {
+			n = n.left
+			continue
+		}
+		idx -= n.left.s()
+		ln += n.left.l()
+		if idx <= n.p.Run {
+			return ln + t.newlines(n.p.Source, n.p.Start, n.p.Start+idx)
+		}
+		idx -= n.p.Run
+		ln += n.nl
+		n = n.right
+	}
+	return ln
 }
 
 func (t *Table) appendPiece(p *Piece) {
-	t.insertPieceAt(len(t.Mods), p)
+	t.insertPieceAt(t.Mods.Len(), p)
 }
 
 func (t *Table) deletePieceAt(index int) {
-	t.Mods = append(t.Mods[:index], t.Mods[index+1:]...)
+	t.Mods.root = t.Mods.root.remove(index)
 }
 
 func (t *Table) insertPieceAt(index int, p *Piece) {
-	if index >= len(t.Mods) {
-		t.Mods = append(t.Mods, p)
-		return
-	}
-	t.Mods = append(t.Mods[:index+1], t.Mods[index:]...)
-	t.Mods[index] = p
-	//t.dump()
+	t.Mods.root = t.Mods.root.insert(index, *p, t.pieceNewlines(p))
+}
+
+func (t *Table) setPieceAt(index int, p *Piece) {
+	t.Mods.root = t.Mods.root.set(index, *p, t.pieceNewlines(p))
 }
 
+// pieceAt returns the piece index and offset inside it for idx; an idx
+// on a piece boundary gives the end of the piece on the left.
 func (t *Table) pieceAt(idx int) (int, int) {
-	i := idx
-	for j, p := range t.Mods {
-		if i <= p.Run {
-			return j, i
-		} else {
-			i = i - p.Run
-		}
-	}
-	return 0, 0
+	return t.Mods.find(idx, true)
+}
+
+// pieceHolding is like pieceAt, but never returns the end of a piece,
+// so that the offset returned always names a rune inside the piece.
+func (t *Table) pieceHolding(idx int) (int, int) {
+	return t.Mods.find(idx, false)
 }
 
+// Insert puts s into the document at rune offset pt
 func (t *Table) Insert(s string, pt int) error {
+	rs := []rune(s)
+	if pt < 0 || pt > t.Size() {
+		return errors.New("point outside of table in Insert")
+	}
+	if len(rs) == 0 {
+		return nil
+	}
+	if t.Mods.Len() == 0 {
+		t.appendPiece(NewPiece(Add, t.addRunes(rs), len(rs)))
+		return nil
+	}
 	e, i := t.pieceAt(pt)
-	p := t.Mods[e]
+	p := t.Mods.At(e)
 	// Appending characters to the "add file" buffer, and
-	if len(t.Add) > 0 && len(t.Add) == (p.Start+p.Run) {
-		t.Add += s
-		p.Run += len(s)
+	// if this piece ends at the end of the add buffer, just grow it.
+	if p.Source == Add && i == p.Run && len(t.Add) == (p.Start+p.Run) {
+		t.addRunes(rs)
+		p.Run += len(rs)
+		t.setPieceAt(e, p)
 		//log.Println("adding a rune to existing slice")
 		return nil
 	}
-	np := NewPiece(Add, len(t.Add), len(s))
-	t.Add += s
+	np := NewPiece(Add, t.addRunes(rs), len(rs))
 	//np.dump(log.New(os.Stderr, "np ", 0))
 	// Updating the entry in piece table (breaking an entry into two or three)
 	if i == 0 {
@@ -172,33 +288,59 @@ func (t *Table) Insert(s string, pt int) error {
 	}
 	if i == p.Run {
 		// insert np at p+1
-		//t.insertPieceAt(e+1, np)
-		t.appendPiece(np)
+		t.insertPieceAt(e+1, np)
 		return nil
 	}
 	// else split the piece and make { left, np, right }
 	left, right := p.splitAt(i)
-	t.deletePieceAt(e)
-	t.insertPieceAt(e, left)
+	t.setPieceAt(e, left)
 	t.insertPieceAt(e+1, right)
 	t.insertPieceAt(e+1, np)
 	return nil
 }
 
+// DeleteRune removes the rune at rune offset idx
 func (t *Table) DeleteRune(idx int) {
-	which, i := t.pieceAt(idx)
-	p := t.Mods[which]
-	if i == 0 || i == p.Run {
-		p.trimRune(i)
+	_ = t.Delete(idx, 1)
+}
+
+// Delete removes length runes starting at rune offset start
+func (t *Table) Delete(start, length int) error {
+	return t.Replace(start, length, "")
+}
+
+// Replace swaps the length runes at start for text, as one change
+// to the piece table: the pieces covering [start, start+length) are cut
+// out and a single new piece for text (if any) is put in their place.
+func (t *Table) Replace(start, length int, text string) error {
+	if start < 0 || length < 0 || start+length > t.Size() {
+		return errors.New("range outside of table in Replace")
 	}
-	// else split into two pieces
-	left, right := p.splitAt(i)
-	//left.Run -= 1 // trim last rune of left (orig idx above)
-	right.Start += 1
-	right.Run -= 1
-	t.deletePieceAt(which)
-	t.insertPieceAt(which, right)
-	t.insertPieceAt(which, left)
+	first := t.boundary(start)
+	last := t.boundary(start + length)
+	for k := first; k < last; k++ {
+		t.deletePieceAt(first)
+	}
+	if rs := []rune(text); len(rs) > 0 {
+		t.insertPieceAt(first, NewPiece(Add, t.addRunes(rs), len(rs)))
+	}
+	return nil
+}
+
+// boundary makes sure a piece starts at rune offset idx, splitting the
+// piece holding idx if needed, and returns the index of that piece.
+func (t *Table) boundary(idx int) int {
+	if idx >= t.Size() {
+		return t.Mods.Len()
+	}
+	j, i := t.pieceHolding(idx)
+	if i == 0 {
+		return j
+	}
+	left, right := t.Mods.At(j).splitAt(i)
+	t.setPieceAt(j, left)
+	t.insertPieceAt(j+1, right)
+	return j + 1
 }
 
 // load a text file from a filename string
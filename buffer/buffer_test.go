@@ -2,7 +2,10 @@ package buffer
 
 import (
 	"fmt"
+	"io/ioutil"
+	"math/rand"
 	"testing"
+	"testing/quick"
 )
 
 func TestSomething(t *testing.T) {
@@ -138,6 +141,27 @@ func TestIndex1(t *testing.T) {
 	//t.Errorf("b is %s", string(b))
 }
 
+func TestIndexOutOfRange(t *testing.T) {
+	tt := NewTable("abc")
+	tt.Insert("xy", 1)
+	for _, idx := range []int{-1, tt.Size(), tt.Size() + 10} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("IndexOf(%d) did not panic", idx)
+				}
+			}()
+			tt.IndexOf(idx)
+		}()
+	}
+	if r := tt.IndexOf(tt.Size() - 1); r != 'c' {
+		t.Errorf("IndexOf(last) %q", r)
+	}
+	if ps := tt.Pieces(); len(ps) != 3 || ps[1].Source != Add || ps[1].Run != 2 {
+		t.Errorf("Pieces %v", ps)
+	}
+}
+
 func TestInsertPiece(t *testing.T) {
 	//fname := "testtext.txt"
 	s := "this is a test. "
@@ -151,7 +175,7 @@ func TestInsertPiece(t *testing.T) {
 	q := NewPiece(Content, 0, len(foo2))
 	tt.insertPieceAt(0, q)
 
-	for i, p := range tt.Mods {
+	for i, p := range tt.Mods.Pieces() {
 		fmt.Printf("%+v %+v\n", i, p)
 	}
 	//t.Errorf("tt.Mods %+v", tt.Mods)
@@ -170,7 +194,7 @@ func TestAppendPiece(t *testing.T) {
 	q := NewPiece(Content, 0, len(foo2))
 	tt.appendPiece(q)
 
-	for i, p := range tt.Mods {
+	for i, p := range tt.Mods.Pieces() {
 		fmt.Printf("%+v %+v\n", i, p)
 	}
 	//t.Errorf("tt.Mods %+v", tt.Mods)
@@ -188,7 +212,7 @@ func TestAppendInsertPiece(t *testing.T) {
 	q := NewPiece(Content, 0, len(foo2))
 	tt.insertPieceAt(1, q)
 
-	for i, p := range tt.Mods {
+	for i, p := range tt.Mods.Pieces() {
 		fmt.Printf("%+v %+v\n", i, p)
 	}
 	//t.Errorf("tt.Mods %+v", tt.Mods)
@@ -211,7 +235,7 @@ func TestPieceHead(t *testing.T) {
 
 	tt := NewTable(s)
 	e, _ := tt.pieceAt(3)
-	p := tt.Mods[e]
+	p := tt.Mods.At(e)
 	s0 := tt.head(p, 3)
 	if s0 != "012" {
 		t.Errorf("s0 %+v", s0)
@@ -239,7 +263,7 @@ func TestPieceTail(t *testing.T) {
 
 	tt := NewTable(s)
 	e, _ := tt.pieceAt(3)
-	p := tt.Mods[e]
+	p := tt.Mods.At(e)
 	s0 := tt.tail(p, 3)
 	if s0 != "3456789" {
 		t.Errorf("s0 %+v", s0)
@@ -463,3 +487,174 @@ func TestAddDeleteRune4(t *testing.T) {
 	}
 
 }
+
+func TestUTF8Files(t *testing.T) {
+	for _, fname := range []string{"../kg/docs/UTF8.txt", "../kg/docs/simplified_chinese.txt"} {
+		dat, err := ioutil.ReadFile(fname)
+		if err != nil {
+			t.Fatalf("%s: %v", fname, err)
+		}
+		s := []rune(string(dat))
+
+		tt := LoadFile(fname)
+		if tt.Size() != len(s) {
+			t.Errorf("%s: Size %d != %d runes", fname, tt.Size(), len(s))
+		}
+		if tt.AllContents() != string(dat) {
+			t.Errorf("%s: AllContents changed the text", fname)
+		}
+
+		mid := len(s) / 2
+		tt.Insert("简体 😀", mid)
+		want := string(s[:mid]) + "简体 😀" + string(s[mid:])
+		if tt.AllContents() != want {
+			t.Errorf("%s: Insert at rune %d corrupted the text", fname, mid)
+		}
+		for i, r := range []rune(want) {
+			if tt.IndexOf(i) != r {
+				t.Errorf("%s: IndexOf(%d) %q != %q", fname, i, tt.IndexOf(i), r)
+				break
+			}
+		}
+
+		tt.DeleteRune(mid + 1)
+		want = string(s[:mid]) + "简 😀" + string(s[mid:])
+		if tt.AllContents() != want {
+			t.Errorf("%s: DeleteRune at rune %d corrupted the text", fname, mid+1)
+		}
+		if tt.Size() != len(s)+3 {
+			t.Errorf("%s: Size %d != %d runes", fname, tt.Size(), len(s)+3)
+		}
+	}
+}
+
+func TestAddRuneUTF8(t *testing.T) {
+	b := NewBuffer("简化字")
+	b.Point = 1
+	b.AddRune('😀')
+	b.AddRune('体')
+	if b.Point != 3 {
+		t.Errorf("Point %d != 3", b.Point)
+	}
+	c := b.T.AllContents()
+	if c != "简😀体化字" {
+		t.Errorf("%+v != %+v", c, "简😀体化字")
+	}
+}
+
+func TestContentsRange(t *testing.T) {
+	s := "0123456789"
+
+	tt := NewTable(s)
+	tt.Insert("xxx", 3)
+	tt.Insert("abc", 8)
+	tt.Insert("é😀", 0)
+	m := []rune("é😀012xxx34abc56789")
+	if tt.AllContents() != string(m) {
+		t.Fatalf("%+v != %+v", tt.AllContents(), string(m))
+	}
+	for start := 0; start <= len(m); start++ {
+		for end := start; end <= len(m); end++ {
+			if c := tt.Contents(start, end); c != string(m[start:end]) {
+				t.Errorf("Contents(%d, %d) %+v != %+v", start, end, c, string(m[start:end]))
+			}
+		}
+	}
+}
+
+func TestDeleteReplace(t *testing.T) {
+	s := "0123456789"
+
+	tt := NewTable(s)
+	tt.Insert("xxx", 3)
+	if err := tt.Delete(2, 5); err != nil {
+		t.Errorf("Delete failed. %v", err)
+	}
+	c := tt.AllContents()
+	if c != "01456789" {
+		t.Errorf("%+v != %+v", c, "01456789")
+	}
+	if err := tt.Replace(1, 3, "简体字"); err != nil {
+		t.Errorf("Replace failed. %v", err)
+	}
+	c = tt.AllContents()
+	if c != "0简体字6789" {
+		t.Errorf("%+v != %+v", c, "0简体字6789")
+	}
+	if err := tt.Delete(6, 3); err == nil {
+		t.Errorf("Delete past the end did not fail.")
+	}
+	tt.DeleteRune(0)
+	tt.DeleteRune(tt.Size() - 1)
+	c = tt.AllContents()
+	if c != "简体字678" {
+		t.Errorf("%+v != %+v", c, "简体字678")
+	}
+}
+
+// TestTableModel runs random edits on a Table and on a plain
+// rune slice, and checks they always hold the same text.
+func TestTableModel(t *testing.T) {
+	words := []string{"", "a", "xyz", "简体", "😀\n", "Lorem ipsum\n"}
+	f := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		m := []rune("Οὐχὶ ταὐτὰ\nπαρίσταταί μοι\n")
+		tt := NewTable(string(m))
+		for op := 0; op < 200; op++ {
+			start := r.Intn(len(m) + 1)
+			length := r.Intn(len(m) - start + 1)
+			w := words[r.Intn(len(words))]
+			switch r.Intn(4) {
+			case 0:
+				tt.Insert(w, start)
+				m = append(m[:start:start], append([]rune(w), m[start:]...)...)
+			case 1:
+				tt.Delete(start, length)
+				m = append(m[:start:start], m[start+length:]...)
+			case 2:
+				tt.Replace(start, length, w)
+				m = append(m[:start:start], append([]rune(w), m[start+length:]...)...)
+			case 3:
+				if start < len(m) {
+					tt.DeleteRune(start)
+					m = append(m[:start:start], m[start+1:]...)
+				}
+			}
+			if tt.Size() != len(m) || tt.AllContents() != string(m) {
+				t.Logf("seed %d op %d: %q != %q", seed, op, tt.AllContents(), string(m))
+				return false
+			}
+			if !sameLines(tt, m) {
+				t.Logf("seed %d op %d: line index wrong for %q", seed, op, string(m))
+				return false
+			}
+			a := r.Intn(len(m) + 1)
+			b := a + r.Intn(len(m)-a+1)
+			if tt.Contents(a, b) != string(m[a:b]) {
+				t.Logf("seed %d op %d: Contents(%d, %d) %q != %q", seed, op, a, b, tt.Contents(a, b), string(m[a:b]))
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+// sameLines checks Lines, LineStart and LineFor against the runes in m
+func sameLines(tt *Table, m []rune) bool {
+	ln := 0
+	for i, r := range m {
+		if tt.LineFor(i) != ln {
+			return false
+		}
+		if r == '\n' {
+			ln++
+			if tt.LineStart(ln) != i+1 {
+				return false
+			}
+		}
+	}
+	return tt.Lines() == ln && tt.LineFor(len(m)) == ln && tt.LineStart(ln+1) == len(m)
+}
@@ -11,10 +11,9 @@ func (t *Table) Dump() {
 	_, filename, line, _ := runtime.Caller(1)
 	l.Println(">> Table Dump", "@", filename, line)
 
-	l.Println("Content", &t.Content, t.Content)
-	l.Println("Add    ", &t.Add, t.Add)
-	for i := 0; i < len(t.Mods); i++ {
-		p := t.Mods[i]
+	l.Println("Content", &t.Content, string(t.Content))
+	l.Println("Add    ", &t.Add, string(t.Add))
+	for i, p := range t.Mods.Pieces() {
 		p.dump(l)
 		l.Println(p, t.RunForMod(i))
 	}
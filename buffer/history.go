@@ -0,0 +1,137 @@
+package buffer
+
+// The Content and Add runes are never changed in place (Add only grows),
+// and the nodes of Mods are never changed once built, so a copy of Mods is
+// a complete, cheap snapshot of the document. History keeps those copies
+// on an undo and a redo stack. Snapshots share all the nodes an edit did
+// not touch, so each one costs only O(log n) nodes per edit made after it.
+
+const historyLimit = 256 // max snapshots kept on each stack
+
+type snapshot struct {
+	name string
+	mods PieceTree
+}
+
+type history struct {
+	undo []*snapshot
+	redo []*snapshot
+	left PieceTree // Mods as Undo or Redo left them, what redo follows on from
+}
+
+func (t *Table) snapshot(name string) *snapshot {
+	return &snapshot{name: name, mods: t.Mods}
+}
+
+func (t *Table) restore(s *snapshot) {
+	t.Mods = s.mods
+}
+
+// same reports whether the snapshot holds the current Mods
+func (s *snapshot) same(mods PieceTree) bool {
+	return s.mods.root == mods.root
+}
+
+// Checkpoint marks the current state as an undo point. Every change made
+// after it, up to the next Checkpoint, is undone as one group.
+func (t *Table) Checkpoint() {
+	h := &t.hist
+	h.redo = nil
+	if n := len(h.undo); n > 0 && h.undo[n-1].same(t.Mods) {
+		return
+	}
+	h.undo = append(h.undo, t.snapshot(""))
+	t.trimHistory()
+}
+
+// NameGroup names the group of changes started by the last Checkpoint,
+// (eg "typing", "cut", "replace")
+func (t *Table) NameGroup(name string) {
+	if n := len(t.hist.undo); n > 0 {
+		t.hist.undo[n-1].name = name
+	}
+}
+
+// UndoName is the name of the group Undo would revert
+func (t *Table) UndoName() string {
+	if n := len(t.hist.undo); n > 0 {
+		return t.hist.undo[n-1].name
+	}
+	return ""
+}
+
+// RedoName is the name of the group Redo would re-apply
+func (t *Table) RedoName() string {
+	if n := len(t.hist.redo); n > 0 {
+		return t.hist.redo[n-1].name
+	}
+	return ""
+}
+
+// CanUndo true if there is a change to undo
+func (t *Table) CanUndo() bool {
+	for _, s := range t.hist.undo {
+		if !s.same(t.Mods) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanRedo true if there is an undone change to redo. Any change made
+// since the Undo does away with it.
+func (t *Table) CanRedo() bool {
+	return len(t.hist.redo) > 0 && t.hist.left.root == t.Mods.root
+}
+
+// Undo puts Mods back to the last checkpoint that differs from now.
+// returns false if there is nothing to undo.
+func (t *Table) Undo() bool {
+	h := &t.hist
+	for len(h.undo) > 0 {
+		s := h.undo[len(h.undo)-1]
+		h.undo = h.undo[:len(h.undo)-1]
+		if s.same(t.Mods) {
+			// checkpoint with no changes after it
+			continue
+		}
+		if !t.CanRedo() {
+			h.redo = nil
+		}
+		h.redo = append(h.redo, t.snapshot(s.name))
+		t.restore(s)
+		h.left = t.Mods
+		t.trimHistory()
+		return true
+	}
+	return false
+}
+
+// Redo re-applies the last group reverted by Undo, if nothing has been
+// changed since. returns false if there is nothing to redo.
+func (t *Table) Redo() bool {
+	h := &t.hist
+	if !t.CanRedo() {
+		h.redo = nil
+		return false
+	}
+	s := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, t.snapshot(s.name))
+	t.restore(s)
+	h.left = t.Mods
+	t.trimHistory()
+	return true
+}
+
+// trimHistory drops the oldest snapshots so that history stays within
+// historyLimit entries.
+func (t *Table) trimHistory() {
+	h := &t.hist
+	for len(h.undo) > historyLimit {
+		h.undo = h.undo[1:]
+	}
+	for len(h.redo) > historyLimit {
+		h.redo = h.redo[1:]
+	}
+}
@@ -0,0 +1,156 @@
+package buffer
+
+import (
+	"testing"
+)
+
+func TestUndoRedo1(t *testing.T) {
+	s := "0123456789"
+
+	tt := NewTable(s)
+
+	tt.Checkpoint()
+	tt.Insert("xxx", 0)
+	tt.NameGroup("typing")
+	tt.Checkpoint()
+	tt.Insert("yyy", tt.Size())
+	tt.NameGroup("paste")
+
+	c := tt.AllContents()
+	if c != "xxx"+s+"yyy" {
+		t.Errorf("%+v != %+v", c, "xxx"+s+"yyy")
+	}
+	if tt.UndoName() != "paste" {
+		t.Errorf("UndoName %+v", tt.UndoName())
+	}
+	if !tt.Undo() {
+		t.Errorf("Undo failed.")
+	}
+	c = tt.AllContents()
+	if c != "xxx"+s {
+		t.Errorf("%+v != %+v", c, "xxx"+s)
+	}
+	if tt.RedoName() != "paste" {
+		t.Errorf("RedoName %+v", tt.RedoName())
+	}
+	if !tt.Undo() {
+		t.Errorf("Undo failed.")
+	}
+	c = tt.AllContents()
+	if c != s {
+		t.Errorf("%+v != %+v", c, s)
+	}
+	if tt.Undo() {
+		t.Errorf("Undo past the first checkpoint.")
+	}
+	if !tt.Redo() || !tt.Redo() {
+		t.Errorf("Redo failed.")
+	}
+	c = tt.AllContents()
+	if c != "xxx"+s+"yyy" {
+		t.Errorf("%+v != %+v", c, "xxx"+s+"yyy")
+	}
+	if tt.Redo() {
+		t.Errorf("Redo past the last change.")
+	}
+}
+
+func TestUndoUncheckpointed(t *testing.T) {
+	s := "0123456789"
+
+	tt := NewTable(s)
+
+	tt.Checkpoint()
+	tt.Insert("xxx", 3)
+	tt.DeleteRune(8)
+	// no checkpoint after the changes, undo still goes back
+	if !tt.Undo() {
+		t.Errorf("Undo failed.")
+	}
+	c := tt.AllContents()
+	if c != s {
+		t.Errorf("%+v != %+v", c, s)
+	}
+}
+
+func TestUndoEmptyCheckpoints(t *testing.T) {
+	s := "0123456789"
+
+	tt := NewTable(s)
+
+	tt.Checkpoint()
+	tt.Insert("xxx", 0)
+	tt.Checkpoint()
+	tt.Checkpoint()
+	if !tt.CanUndo() {
+		t.Errorf("CanUndo failed.")
+	}
+	if !tt.Undo() {
+		t.Errorf("Undo failed.")
+	}
+	c := tt.AllContents()
+	if c != s {
+		t.Errorf("%+v != %+v", c, s)
+	}
+	if tt.CanUndo() {
+		t.Errorf("CanUndo with nothing left.")
+	}
+}
+
+func TestCheckpointClearsRedo(t *testing.T) {
+	s := "0123456789"
+
+	tt := NewTable(s)
+
+	tt.Checkpoint()
+	tt.Insert("xxx", 0)
+	tt.Undo()
+	if !tt.CanRedo() {
+		t.Errorf("CanRedo failed.")
+	}
+	tt.Checkpoint()
+	tt.Insert("abc", 0)
+	if tt.CanRedo() {
+		t.Errorf("Redo survived a new change.")
+	}
+}
+
+func TestChangeClearsRedo(t *testing.T) {
+	tt := NewTable("abc")
+
+	tt.Checkpoint()
+	tt.Insert("X", 0)
+	tt.Undo()
+	tt.Insert("Y", 3)
+	if tt.CanRedo() {
+		t.Errorf("Redo survived a change without a checkpoint.")
+	}
+	if tt.Redo() {
+		t.Errorf("Redo over a change.")
+	}
+	if c := tt.AllContents(); c != "abcY" {
+		t.Errorf("%+v != %+v", c, "abcY")
+	}
+}
+
+func TestHistoryBounded(t *testing.T) {
+	s := "0123456789"
+
+	tt := NewTable(s)
+
+	for i := 0; i < 10*historyLimit; i++ {
+		tt.Checkpoint()
+		tt.Insert("x", 0)
+	}
+	if len(tt.hist.undo) > historyLimit {
+		t.Errorf("undo history grew to %d", len(tt.hist.undo))
+	}
+	for tt.Undo() {
+	}
+	if len(tt.hist.redo) > historyLimit {
+		t.Errorf("redo history grew to %d", len(tt.hist.redo))
+	}
+	if tt.Size() != len(s)+9*historyLimit {
+		t.Errorf("Size %d after undoing all kept history", tt.Size())
+	}
+}
@@ -0,0 +1,221 @@
+package buffer
+
+import "fmt"
+
+// PieceTree holds the pieces of a Table in document order, as a balanced
+// (AVL) tree. Every node caches the number of pieces, runes and newlines
+// in its subtree, so finding the piece for an offset or a line, and
+// inserting or removing a piece, are all O(log n).
+//
+// Nodes are never changed once built; an edit copies the nodes on the path
+// it touches and shares the rest. Copying a PieceTree is therefore a cheap
+// snapshot of the whole document, which is what history uses.
+type PieceTree struct {
+	root *node
+}
+
+type node struct {
+	p           Piece
+	nl          int // newlines in p
+	left, right *node
+	height      int
+	count       int // pieces in subtree
+	size        int // runes in subtree
+	lines       int // newlines in subtree
+}
+
+func mk(p Piece, nl int, left, right *node) *node {
+	n := &node{p: p, nl: nl, left: left, right: right}
+	n.height = 1 + max(left.h(), right.h())
+	n.count = 1 + left.c() + right.c()
+	n.size = p.Run + left.s() + right.s()
+	n.lines = nl + left.l() + right.l()
+	return n
+}
+
+// nil-safe accessors
+func (n *node) h() int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+func (n *node) c() int {
+	if n == nil {
+		return 0
+	}
+	return n.count
+}
+func (n *node) s() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+func (n *node) l() int {
+	if n == nil {
+		return 0
+	}
+	return n.lines
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func rotateRight(n *node) *node {
+	l := n.left
+	return mk(l.p, l.nl, l.left, mk(n.p, n.nl, l.right, n.right))
+}
+
+func rotateLeft(n *node) *node {
+	r := n.right
+	return mk(r.p, r.nl, mk(n.p, n.nl, n.left, r.left), r.right)
+}
+
+func balance(n *node) *node {
+	bf := n.left.h() - n.right.h()
+	if bf > 1 {
+		if n.left.left.h() < n.left.right.h() {
+			n = mk(n.p, n.nl, rotateLeft(n.left), n.right)
+		}
+		return rotateRight(n)
+	}
+	if bf < -1 {
+		if n.right.right.h() < n.right.left.h() {
+			n = mk(n.p, n.nl, n.left, rotateRight(n.right))
+		}
+		return rotateLeft(n)
+	}
+	return n
+}
+
+// insert puts p before the i-th piece of the subtree
+func (n *node) insert(i int, p Piece, nl int) *node {
+	if n == nil {
+		return mk(p, nl, nil, nil)
+	}
+	if i <= n.left.c() {
+		return balance(mk(n.p, n.nl, n.left.insert(i, p, nl), n.right))
+	}
+	return balance(mk(n.p, n.nl, n.left, n.right.insert(i-n.left.c()-1, p, nl)))
+}
+
+// remove takes out the i-th piece of the subtree
+func (n *node) remove(i int) *node {
+	lc := n.left.c()
+	if i < lc {
+		return balance(mk(n.p, n.nl, n.left.remove(i), n.right))
+	}
+	if i > lc {
+		return balance(mk(n.p, n.nl, n.left, n.right.remove(i-lc-1)))
+	}
+	if n.left == nil {
+		return n.right
+	}
+	if n.right == nil {
+		return n.left
+	}
+	m := n.right.at(0)
+	return balance(mk(m.p, m.nl, n.left, n.right.remove(0)))
+}
+
+// set swaps the i-th piece of the subtree for p
+func (n *node) set(i int, p Piece, nl int) *node {
+	lc := n.left.c()
+	if i < lc {
+		return mk(n.p, n.nl, n.left.set(i, p, nl), n.right)
+	}
+	if i > lc {
+		return mk(n.p, n.nl, n.left, n.right.set(i-lc-1, p, nl))
+	}
+	return mk(p, nl, n.left, n.right)
+}
+
+func (n *node) at(i int) *node {
+	for n != nil {
+		lc := n.left.c()
+		if i < lc {
+			n = n.left
+		} else if i > lc {
+			i -= lc + 1
+			n = n.right
+		} else {
+			return n
+		}
+	}
+	return nil
+}
+
+func (n *node) each(f func(p *Piece)) {
+	if n == nil {
+		return
+	}
+	n.left.each(f)
+	p := n.p
+	f(&p)
+	n.right.each(f)
+}
+
+func build(ps []Piece, nls []int) *node {
+	if len(ps) == 0 {
+		return nil
+	}
+	m := len(ps) / 2
+	return mk(ps[m], nls[m], build(ps[:m], nls[:m]), build(ps[m+1:], nls[m+1:]))
+}
+
+// Len is the number of pieces
+func (pt PieceTree) Len() int { return pt.root.c() }
+
+// Size is the number of runes in all the pieces
+func (pt PieceTree) Size() int { return pt.root.s() }
+
+// Lines is the number of newlines in all the pieces
+func (pt PieceTree) Lines() int { return pt.root.l() }
+
+// At returns (a copy of) the i-th piece, or nil if there is none
+func (pt PieceTree) At(i int) *Piece {
+	n := pt.root.at(i)
+	if n == nil {
+		return nil
+	}
+	p := n.p
+	return &p
+}
+
+// Pieces returns (copies of) all the pieces in document order
+func (pt PieceTree) Pieces() []*Piece {
+	ps := make([]*Piece, 0, pt.Len())
+	pt.root.each(func(p *Piece) { ps = append(ps, p) })
+	return ps
+}
+
+// find returns the piece index and the offset inside it for rune offset idx.
+// With atEnd an offset on a piece boundary gives the end of the piece on
+// its left (good for inserting), otherwise the start of the piece on its
+// right (good for reading the rune there). It panics, as indexing a slice
+// would, for an offset outside the text.
+func (pt PieceTree) find(idx int, atEnd bool) (int, int) {
+	at := idx
+	n, j := pt.root, 0
+	for n != nil {
+		ls := n.left.s()
+		if idx < ls || (atEnd && idx == ls && n.left != nil) {
+			n = n.left
+			continue
+		}
+		idx -= ls
+		j += n.left.c()
+		if idx < n.p.Run || (atEnd && idx == n.p.Run) {
+			return j, idx
+		}
+		idx -= n.p.Run
+		j++
+		n = n.right
+	}
+	panic(fmt.Sprintf("buffer: offset %d out of range [0:%d]", at, pt.Size()))
+}
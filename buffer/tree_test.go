@@ -0,0 +1,110 @@
+package buffer
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+const benchPieces = 1000000
+
+// bigTable makes a table of n pieces, each two runes long, with a
+// newline every eight runes.
+func bigTable(n int) *Table {
+	t := NewTable(strings.Repeat("abcdefg\n", n/4))
+	ps := make([]Piece, n)
+	nls := make([]int, n)
+	for i := range ps {
+		ps[i] = Piece{Source: Content, Start: 2 * i, Run: 2}
+		nls[i] = t.pieceNewlines(&ps[i])
+	}
+	t.Mods.root = build(ps, nls)
+	return t
+}
+
+func checkTree(t *testing.T, n *node) {
+	if n == nil {
+		return
+	}
+	checkTree(t, n.left)
+	checkTree(t, n.right)
+	if d := n.left.h() - n.right.h(); d > 1 || d < -1 {
+		t.Fatalf("node out of balance by %d", d)
+	}
+	if n.count != 1+n.left.c()+n.right.c() || n.size != n.p.Run+n.left.s()+n.right.s() ||
+		n.lines != n.nl+n.left.l()+n.right.l() {
+		t.Fatalf("node sums are wrong %+v", n)
+	}
+}
+
+func TestTreeBalanced(t *testing.T) {
+	tt := NewTable(strings.Repeat("Lorem ipsum\n", 100))
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		pt := r.Intn(tt.Size() + 1)
+		if i%3 == 2 && pt < tt.Size() {
+			tt.DeleteRune(pt)
+		} else {
+			tt.Insert("x\n", pt)
+		}
+	}
+	checkTree(t, tt.Mods.root)
+	if h := tt.Mods.root.h(); h > 2*20 {
+		t.Errorf("tree of %d pieces is %d high", tt.Mods.Len(), h)
+	}
+}
+
+func TestBigTable(t *testing.T) {
+	tt := bigTable(benchPieces)
+	if tt.Mods.Len() != benchPieces || tt.Size() != 2*benchPieces || tt.Lines() != benchPieces/4 {
+		t.Errorf("bigTable %d pieces %d runes %d lines", tt.Mods.Len(), tt.Size(), tt.Lines())
+	}
+	if tt.LineStart(1000) != 8000 || tt.LineFor(8000) != 1000 || tt.IndexOf(8007) != '\n' {
+		t.Errorf("bigTable line lookups are wrong")
+	}
+}
+
+func BenchmarkInsert(b *testing.B) {
+	tt := bigTable(benchPieces)
+	r := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tt.Insert("x", r.Intn(tt.Size()))
+	}
+}
+
+func BenchmarkDelete(b *testing.B) {
+	tt := bigTable(benchPieces)
+	r := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tt.DeleteRune(r.Intn(tt.Size()))
+	}
+}
+
+func BenchmarkIndexOf(b *testing.B) {
+	tt := bigTable(benchPieces)
+	r := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tt.IndexOf(r.Intn(tt.Size()))
+	}
+}
+
+func BenchmarkLineStart(b *testing.B) {
+	tt := bigTable(benchPieces)
+	r := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tt.LineStart(r.Intn(tt.Lines()))
+	}
+}
+
+func BenchmarkLineFor(b *testing.B) {
+	tt := bigTable(benchPieces)
+	r := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tt.LineFor(r.Intn(tt.Size()))
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kristofer/ke/web"
+)
+
+// ke-server serves the web frontend, the page and all, from one binary:
+//
+//	ke-server -root ~/src -token sesame
+//
+// and open http://localhost:8005/?token=sesame
+func main() {
+	addr := flag.String("addr", "localhost", "listen on `host`, \"\" for all interfaces")
+	port := flag.Int("port", 8005, "listen on `port`")
+	cert := flag.String("cert", "", "serve HTTPS with the certificate in `file`, PEM")
+	key := flag.String("key", "", "and its private key, in `file`")
+	token := flag.String("token", os.Getenv("KE_TOKEN"), "the `token` to log in with, $KE_TOKEN by default")
+	origins := flag.String("origins", "", "other `origins`, comma separated, allowed to open the websocket")
+	root := flag.String("root", ".", "edit files under `dir` only")
+	static := flag.String("static", "", "serve the page from `dir` rather than the built in one")
+	grace := flag.Duration("grace", web.DefaultGrace, "keep a session this `long` for its browser to come back")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if (*cert == "") != (*key == "") {
+		fail(fmt.Errorf("-cert and -key go together"))
+	}
+
+	dir, err := filepath.Abs(*root)
+	if err == nil {
+		err = isDir(dir)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	es := web.NewEditorServer()
+	es.Server.Addr = net.JoinHostPort(*addr, strconv.Itoa(*port))
+	es.CertFile, es.KeyFile = *cert, *key
+	es.Token = *token
+	if *origins != "" {
+		es.Origins = strings.Split(*origins, ",")
+	}
+	es.Sessions.Root = dir
+	es.Sessions.Grace = *grace
+	if *static != "" {
+		if err := isDir(*static); err != nil {
+			fail(err)
+		}
+		es.Static = os.DirFS(*static)
+	}
+
+	log.Println("editing files under", dir)
+	log.Println("open", pageURL(*addr, *port, *cert != "", *token))
+	es.StartEditorServer()
+}
+
+// pageURL gives the address of the editor's page, with the token to log in
+func pageURL(addr string, port int, tls bool, token string) string {
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	if addr == "" || addr == "0.0.0.0" || addr == "::" {
+		addr = "localhost"
+	}
+	u := scheme + "://" + net.JoinHostPort(addr, strconv.Itoa(port)) + "/"
+	if token != "" {
+		u += "?token=" + url.QueryEscape(token)
+	}
+	return u
+}
+
+func isDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if err == nil && !fi.IsDir() {
+		err = fmt.Errorf("%s is not a directory", dir)
+	}
+	return err
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "ke-server:", err)
+	os.Exit(1)
+}
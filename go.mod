@@ -6,6 +6,7 @@ require (
 	fyne.io/fyne/v2 v2.3.4
 	github.com/creack/pty v1.1.18
 	github.com/gorilla/websocket v1.5.0
+	github.com/stretchr/testify v1.8.0
 )
 
 require (
@@ -28,7 +29,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/srwiley/oksvg v0.0.0-20220731023508-a61f04f16b76 // indirect
 	github.com/srwiley/rasterx v0.0.0-20210519020934-456a8d69b780 // indirect
-	github.com/stretchr/testify v1.8.0 // indirect
 	github.com/tevino/abool v1.2.0 // indirect
 	github.com/yuin/goldmark v1.4.13 // indirect
 	golang.org/x/image v0.3.0 // indirect
@@ -6,15 +6,16 @@ import (
 )
 
 /*
- * Buffer is where all the operations on the main rune array are implemented.
- * Because of the Gap, all the indexing around it should be done by these routines.
+ * Buffer is where all the editing operations on a text are implemented.
+ * The runes themselves are kept in a Storage (see storage.go), which all
+ * the indexing into the text goes through.
  */
 
 // Buffer main struct
 type Buffer struct {
 	Point   int
-	postLen int
-	data    []rune
+	text    Storage
+	storage StorageKind
 
 	Next       *Buffer
 	Mark       int
@@ -31,90 +32,95 @@ type Buffer struct {
 	Buffername string //[b_bnameSTRBUF_S];   /* buffer name */
 	Flags      byte   /* char b_flags buffer flags */
 	modified   bool
+	undo       undoList
+	hl         *highlighter
 }
 
 // MarkModified xxx
 func (bp *Buffer) MarkModified() {
 	bp.modified = true
-	bp.TextSize = bp.Point + bp.postLen
+	bp.TextSize = bp.text.Len()
 }
 
 // NewBuffer - Create a new Buffer
 func NewBuffer() *Buffer {
-	nb := Buffer{}
+	return NewBufferStorage(GapStorage)
+}
+
+// NewBufferStorage - Create a new Buffer keeping its text in kind of Storage
+func NewBufferStorage(kind StorageKind) *Buffer {
+	nb := Buffer{storage: kind}
 	nb.setText("\n")
 	return &nb
 }
 
+// Storage is the kind of Storage holding the text
+func (bp *Buffer) Storage() StorageKind {
+	return bp.storage
+}
+
+// SetStorage moves the text into a new kind of Storage
+func (bp *Buffer) SetStorage(kind StorageKind) {
+	if kind == bp.storage {
+		return
+	}
+	bp.storage = kind
+	bp.text = newStorage(kind, bp.getText())
+}
+
 // setText xxx
 func (bp *Buffer) setText(s string) {
-	bp.data = []rune(s)
+	bp.text = newStorage(bp.storage, s)
+	bp.hl = nil
 	bp.Point = 0
-	bp.postLen = len(bp.data)
-	bp.TextSize = bp.Point + bp.postLen
+	bp.TextSize = bp.text.Len()
+	bp.undo.reset()
 }
 
 // getText  xxx
 func (bp *Buffer) getText() string {
-	//bp.TextSize = bp.Point + bp.postLen
-	ret := make([]rune, bp.Point+bp.postLen)
-	copy(ret, bp.data)
-	copy(ret[bp.Point:], bp.data[bp.postStart():])
-	return string(ret)
+	return string(bp.text.Slice(0, bp.text.Len()))
 }
 
 // RuneAt finally reliable!! (well, maybe not)
 func (bp *Buffer) RuneAt(pt int) (rune, error) {
 	//log.Println("RuneAt pt = ", pt)
-	if pt >= len(bp.data) {
-		return 0, errors.New("beyond data buffer in RuneAt")
+	if pt >= bp.text.Len() {
+		return 0, errors.New("beyond end of text in RuneAt")
 	}
 	if pt < 0 {
-		//return '\u0000', errors.New("negative buffer pointer in RuneAt")
-		pt = 0
-	}
-	if npt := bp.dataPointForBufferPoint(pt); npt < len(bp.data) {
-		return bp.data[npt], nil
+		return 0, errors.New("negative buffer pointer in RuneAt")
 	}
-	return 0, errors.New("ran over end of data buffer in RuneAt")
+	return bp.text.RuneAt(pt), nil
 }
 
-func (bp *Buffer) dataPointForBufferPoint(pt int) int {
-	npt := 0
-	if pt < bp.Point {
-		npt = pt
+// getRegion returns the text in [from, to), or an error if that is
+// not all inside the buffer
+func (bp *Buffer) getRegion(from, to int) (string, error) {
+	if from < 0 || to > bp.text.Len() || from > to {
+		return "", fmt.Errorf("region %d-%d is outside the buffer", from, to)
 	}
-	if pt >= bp.Point && pt < len(bp.data) {
-		npt = pt + bp.gapLen()
-	}
-	return npt
+	return string(bp.text.Slice(from, to)), nil
 }
 
 // AddRune add a run to the buffer
 func (bp *Buffer) AddRune(ch rune) {
-	if bp.gapLen() == 0 {
-		_ = bp.GrowGap(gapchunk)
-	}
-	bp.data[bp.Point] = ch
+	bp.recordInsert(bp.Point, []rune{ch}, true)
+	bp.text.Insert(bp.Point, []rune{ch})
+	bp.textChanged(bp.Point, nil, []rune{ch})
 	bp.Point++
 	bp.MarkModified()
 }
 
 // SetPoint set the current point to np
 func (bp *Buffer) SetPoint(np int) {
-	bp.CollapseGap()
-	//bp.MoveGap(np - bp.Point)
-	// move gap <-(left) by np chars
-	gs := bp.gapStart()
-	//log.Printf("gap start %d len %d new pt %d dist %d\n", gs, bp.gapLen(), np, gs-np)
-	f := 0
-	for i := gs - np; i > 0; i-- {
-		bp.data[bp.postStart()-1] = bp.data[bp.Point-1]
-		bp.Point--
-		bp.postLen++
-		f++
-	}
-	//log.Printf("shuffled %d\n", f)
+	if np > bp.text.Len() {
+		np = bp.text.Len()
+	}
+	if np < 0 {
+		np = 0
+	}
+	bp.Point = np
 
 	if bp.PageEnd < bp.Point {
 		bp.Reframe = true
@@ -128,123 +134,69 @@ func (bp *Buffer) setCursor() {
 	bp.PointCol = x
 }
 
-func (bp *Buffer) gapStart() int {
-	return bp.Point
-}
-
-func (bp *Buffer) gapLen() int {
-	return bp.postStart() - bp.Point
-}
-
-func (bp *Buffer) postStart() int {
-	return len(bp.data) - bp.postLen
-}
-
-// CollapseGap moves the gap to the end of the buffer
-func (bp *Buffer) CollapseGap() {
-	//for i := bp.Point; bp.postLen > 0; i++ {
-	for bp.postLen > 0 {
-		bp.data[bp.Point] = bp.data[len(bp.data)-bp.postLen]
-		bp.Point++
-		bp.postLen--
-	}
+// Insert adds the string at point
+func (bp *Buffer) Insert(s string) {
+	rs := []rune(s)
+	bp.recordInsert(bp.Point, rs, false)
+	bp.insertRunes(rs)
 }
 
-// Insert adds the string, growing the gap if needed.
-func (bp *Buffer) Insert(s string) {
-	if bp.gapLen() < len(s) {
-		newGap := len(s) + 32
-		_ = bp.GrowGap(newGap)
-	}
-	copy(bp.data[bp.gapStart():], []rune(s))
-	bp.Point += len(s)
+func (bp *Buffer) insertRunes(rs []rune) {
+	bp.text.Insert(bp.Point, rs)
+	bp.textChanged(bp.Point, nil, rs)
+	bp.Point += len(rs)
 	bp.MarkModified()
 }
 
 // getTextForLines return string for [l1, l2) (l2 not included)
 func (bp *Buffer) getTextForLines(l1, l2 int) string {
-	pt1 := bp.PointForLine(l1)
-	ret := make([]rune, bp.PointForLine(l2)-pt1)
-	for i, j := pt1, 0; j < len(ret); i++ {
-		rch, err := bp.RuneAt(i)
-		checkErr(err)
-		ret[j] = rch
-		j++
-	}
-	return string(ret)
-}
-
-// GrowGap makes the gap bigger by n
-// not sure why I need this.
-func (bp *Buffer) GrowGap(n int) bool {
-	newData := make([]rune, len(bp.data)+n)
-	copy(newData, bp.data[:bp.Point])
-	copy(newData[bp.postStart()+n:],
-		bp.data[bp.postStart():])
-	bp.data = newData
-	bp.TextSize = bp.Point + bp.postLen
-	return true
-}
-
-// MoveGap moves the gap to a Point
-func (bp *Buffer) MoveGap(offset int) int {
-
-	if offset < 0 {
-		if bp.postLen == 0 {
-			return 0
-		}
-		for i := 0; i < offset; i++ {
-			bp.data[bp.Point] = bp.data[len(bp.data)-bp.postLen]
-			bp.Point++
-			bp.postLen--
-		}
+	pt1, pt2 := bp.PointForLine(l1), bp.PointForLine(l2)
+	if pt2 < pt1 {
+		return ""
 	}
-	if offset > 0 {
-		if bp.Point == 0 {
-			return 0
-		}
-		for i := offset; i < 0; i++ {
-			bp.data[bp.postStart()-1] = bp.data[bp.Point-1]
-			bp.Point--
-			bp.postLen++
-		}
-	}
-
-	return offset
+	return string(bp.text.Slice(pt1, pt2))
 }
 
 // Remove extent runes starting at from point
 func (bp *Buffer) Remove(from int, extent int) {
+	opoint := bp.Point
 	bp.SetPoint(from)
-	for k := 0; k < extent; k++ {
-		bp.Delete()
+	if extent > bp.text.Len()-bp.Point {
+		extent = bp.text.Len() - bp.Point
+	}
+	if extent <= 0 {
+		return
 	}
+	bp.recordDelete(bp.Point, bp.text.Slice(bp.Point, bp.Point+extent), opoint)
+	bp.deleteRunes(extent)
 }
 
-//LineStart xxx
+// Replace swaps the n runes at from for s as one undo step,
+// leaving point after s
+func (bp *Buffer) Replace(from, n int, s string) {
+	opoint := bp.Point
+	bp.SetPoint(from)
+	if n > bp.text.Len()-bp.Point {
+		n = bp.text.Len() - bp.Point
+	}
+	if n < 0 {
+		n = 0
+	}
+	rs := []rune(s)
+	bp.recordReplace(bp.Point, bp.text.Slice(bp.Point, bp.Point+n), rs, opoint)
+	bp.deleteRunes(n)
+	bp.insertRunes(rs)
+}
+
+// LineStart find the point at the start of this line
 func (bp *Buffer) LineStart(point int) int {
-	if point > len(bp.data)-bp.gapLen() {
-		point = len(bp.data) - bp.gapLen()
-	}
-	sp := point - 1
-	p, err := bp.RuneAt(sp)
-	checkErr(err)
-	if p == '\n' {
-		sp++
-		return sp
-	}
-	for x := sp; x > 0; x-- {
-		if x == 0 {
-			return 0
-		}
-		p, err = bp.RuneAt(x)
-		checkErr(err)
-		if p == '\n' {
-			x++
-			return x
-		}
+	if point > bp.text.Len() {
+		point = bp.text.Len()
 	}
-	return 0
+	if point < 0 {
+		point = 0
+	}
+	return bp.text.LineStart(bp.text.LineFor(point))
 }
 
 // LineEnd find the point at end of this line
@@ -252,25 +204,22 @@ func (bp *Buffer) LineEnd(point int) int {
 	if point < 0 {
 		return 0
 	}
-	ep := len(bp.data) - bp.gapLen()
-	for {
-		if point >= ep {
-			return ep - 1
-		}
-		p, err := bp.RuneAt(point)
-		checkErr(err)
-		if p == '\n' {
-			return point
-		}
-		point++
+	size := bp.text.Len()
+	if size == 0 {
+		return 0
+	}
+	if point >= size {
+		return size - 1
 	}
+	// the first newline at or after point
+	if n := bp.text.LineFor(point) + 1; n <= bp.text.Lines() {
+		return bp.text.LineStart(n) - 1
+	}
+	return size - 1
 }
 
 // LineLenAtPoint length of line at point
 func (bp *Buffer) LineLenAtPoint(point int) int {
-	if point >= len(bp.data) {
-		point = len(bp.data) - 1
-	}
 	if point < 0 {
 		point = 0
 	}
@@ -284,40 +233,21 @@ func (bp *Buffer) PointForLine(ln int) int {
 	if ln <= 1 {
 		return 0
 	}
-	lines := 0
-	for pt := 0; pt < bp.TextSize; pt++ {
-		etch, err := bp.RuneAt(pt)
-		checkErr(err)
-		if etch == '\n' {
-			lines++
-		}
-		if lines == ln {
-			return bp.LineStart(pt)
-		}
+	if ln <= bp.text.Lines() {
+		return bp.text.LineStart(ln - 1)
 	}
 	return bp.LineEnd(bp.TextSize) // -1
 }
 
 // LineForPoint returns the line number of point (origin = 1)
 func (bp *Buffer) LineForPoint(point int) (line int) {
-	line = 1
-	pt := 0
 	if point >= bp.TextSize {
 		point = bp.TextSize - 1
 	}
-	doIncr := false
-	for pt = 0; pt <= point; pt++ {
-		if doIncr {
-			line++
-			doIncr = false
-		}
-		etch, err := bp.RuneAt(pt)
-		checkErr(err)
-		if etch == '\n' {
-			doIncr = true
-		}
+	if point < 0 {
+		point = 0
 	}
-	return
+	return 1 + bp.text.LineFor(point)
 }
 
 // ColumnForPoint returns the column (origin = 1) of pt
@@ -331,7 +261,7 @@ func (bp *Buffer) ColumnForPoint(point int) (column int) {
 // XYForPoint returns the cursor location for a pt in the buffer
 func (bp *Buffer) XYForPoint(pt int) (x, y int) {
 	x = bp.ColumnForPoint(pt)
-	if bp.TextSize = bp.Point + bp.postLen; pt >= bp.TextSize {
+	if bp.TextSize = bp.text.Len(); pt >= bp.TextSize {
 		x = bp.ColumnForPoint(bp.LineEnd(pt))
 	}
 	y = bp.LineForPoint(pt)
@@ -353,6 +283,9 @@ func (bp *Buffer) PointForXY(x, y int) (finalpt int) {
 func (bp *Buffer) SegStart(start, finish, limit int) int {
 	//var p rune
 	c := 0
+	if start < 0 {
+		start = 0
+	}
 	scan := start
 
 	for scan < finish {
@@ -360,8 +293,7 @@ func (bp *Buffer) SegStart(start, finish, limit int) int {
 		if scan >= bp.TextSize {
 			return bp.TextSize
 		}
-		rch, err := bp.RuneAt(scan)
-		checkErr(err)
+		rch := bp.text.RuneAt(scan)
 
 		if rch == '\n' {
 			c = 0
@@ -394,8 +326,7 @@ func (bp *Buffer) SegNext(start, finish, limit int) int {
 		if scan >= bp.TextSize {
 			return bp.TextSize
 		}
-		rch, err := bp.RuneAt(scan)
-		checkErr(err)
+		rch := bp.text.RuneAt(scan)
 		if limit <= c {
 			break
 		}
@@ -417,10 +348,22 @@ func (bp *Buffer) SegNext(start, finish, limit int) int {
 
 // Delete remove a rune forward
 func (bp *Buffer) Delete() {
-	if bp.postLen == 0 {
+	if bp.Point >= bp.text.Len() {
+		return
+	}
+	bp.recordDelete(bp.Point, bp.text.Slice(bp.Point, bp.Point+1), bp.Point)
+	bp.deleteRunes(1)
+}
+
+// deleteRunes removes n runes forward of point, without recording undo
+func (bp *Buffer) deleteRunes(n int) {
+	if n > bp.text.Len()-bp.Point {
+		n = bp.text.Len() - bp.Point
+	}
+	if n <= 0 {
 		return
 	}
-	bp.postLen--
+	bp.textChanged(bp.Point, bp.text.Delete(bp.Point, n), nil)
 	bp.MarkModified()
 }
 
@@ -429,6 +372,9 @@ func (bp *Buffer) Backspace() {
 	if bp.Point == 0 {
 		return
 	}
+	rs := bp.text.Delete(bp.Point-1, 1)
+	bp.recordDelete(bp.Point-1, rs, bp.Point)
+	bp.textChanged(bp.Point-1, rs, nil)
 	bp.Point--
 	bp.MarkModified()
 }
@@ -470,12 +416,10 @@ func (bp *Buffer) PointDown() {
 // PointNext move point left one
 func (bp *Buffer) PointNext() {
 	// this is from the END OF BUFFER nonsense I had to fix.
-	if bp.postLen <= 1 { //== 0 {
+	if bp.text.Len()-bp.Point <= 1 { //== 0 {
 		return
 	}
-	bp.data[bp.Point] = bp.data[bp.postStart()]
 	bp.Point++
-	bp.postLen--
 }
 
 // PointPrevious move point right one
@@ -483,10 +427,7 @@ func (bp *Buffer) PointPrevious() {
 	if bp.Point == 0 {
 		return
 	}
-
-	bp.data[bp.postStart()-1] = bp.data[bp.Point-1]
 	bp.Point--
-	bp.postLen++
 }
 
 // UpUp Move up one screen line
@@ -514,28 +455,31 @@ func (bp *Buffer) GetLineStats() (curline int, lastline int) {
 	return curline, lastline
 }
 
-func (bp *Buffer) gotoLine(ln int) {
+// gotoLine moves point to the start of line ln, or as near to it as
+// there is, returning an error if the buffer has no line ln
+func (bp *Buffer) gotoLine(ln int) error {
 	pt := bp.PointForLine(ln)
 	bp.SetPoint(pt)
+	if ln < 1 {
+		return fmt.Errorf("no line %d", ln)
+	}
+	if last := bp.LineForPoint(bp.TextSize); ln > last {
+		return fmt.Errorf("line %d is past the end of the buffer (%d lines)", ln, last)
+	}
+	return nil
 }
 
 // DebugPrint prints out a view of the buffer and the gap and so on.
 func (bp *Buffer) DebugPrint() {
-	fmt.Printf("*********(gap)\n")
-	for i := 0; i < len(bp.data); i++ {
-		if i >= bp.gapStart() && i < bp.gapStart()+bp.gapLen() {
-			fmt.Printf("@")
-		} else if i < bp.Point {
-			if bp.data[i] == '\n' {
-				fmt.Printf("%c\n", 0x00B6)
-			} else {
-				fmt.Printf("%c", bp.data[i])
-			}
-		} else {
-			if bp.data[i] == '\n' {
+	fmt.Printf("*********(%s)\n", bp.storage)
+	if g, ok := bp.text.(*gapBuffer); ok {
+		g.debugPrint()
+	} else {
+		for _, r := range bp.getText() {
+			if r == '\n' {
 				fmt.Printf("%c\n", 0x00B6)
 			} else {
-				fmt.Printf("%c", bp.data[i])
+				fmt.Printf("%c", r)
 			}
 		}
 	}
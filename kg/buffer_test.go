@@ -11,6 +11,11 @@ func printIdxForLine(ln int) {
 	fmt.Println()
 }
 
+// gapOf is the gap buffer holding gb's text
+func gapOf(gb *Buffer) *gapBuffer {
+	return gb.text.(*gapBuffer)
+}
+
 func TestBufferGrow(t *testing.T) {
 
 	// if total != 10 {
@@ -63,7 +68,7 @@ func TestCollapseGap(t *testing.T) {
 
 	gb.DebugPrint()
 
-	gb.CollapseGap()
+	gapOf(gb).moveGap(gb.TextSize)
 
 	gb.DebugPrint()
 	//t.Error("End of Buffer")
@@ -528,72 +533,72 @@ func TestSetPoint(t *testing.T) {
 	gb.AddRune('s')
 	gb.AddRune('\n')
 
-	assert.Equal(t, 8, gb.gapStart())
-	assert.Equal(t, 27, gb.gapLen())
+	assert.Equal(t, 8, gb.Point)
+	assert.Equal(t, 27, gapOf(gb).gapLen())
 	gb.PointNext()
-	assert.Equal(t, 9, gb.gapStart())
-	assert.Equal(t, 27, gb.gapLen())
+	assert.Equal(t, 9, gb.Point)
+	assert.Equal(t, 27, gapOf(gb).gapLen())
 	gb.AddRune('X')
-	assert.Equal(t, 10, gb.gapStart())
-	assert.Equal(t, 26, gb.gapLen())
+	assert.Equal(t, 10, gb.Point)
+	assert.Equal(t, 26, gapOf(gb).gapLen())
 	gb.DebugPrint()
 
 	gb.SetPoint(5)
-	assert.Equal(t, 5, gb.gapStart())
-	assert.Equal(t, 26, gb.gapLen())
+	assert.Equal(t, 5, gb.Point)
+	assert.Equal(t, 26, gapOf(gb).gapLen())
 	gb.SetPoint(8)
-	assert.Equal(t, 8, gb.gapStart())
-	assert.Equal(t, 26, gb.gapLen())
+	assert.Equal(t, 8, gb.Point)
+	assert.Equal(t, 26, gapOf(gb).gapLen())
 	gb.DebugPrint()
 	gb.SetPoint(15)
-	assert.Equal(t, 15, gb.gapStart())
-	assert.Equal(t, 26, gb.gapLen())
+	assert.Equal(t, 15, gb.Point)
+	assert.Equal(t, 26, gapOf(gb).gapLen())
 	gb.SetPoint(10)
-	assert.Equal(t, 10, gb.gapStart())
-	assert.Equal(t, 26, gb.gapLen())
+	assert.Equal(t, 10, gb.Point)
+	assert.Equal(t, 26, gapOf(gb).gapLen())
 	gb.AddRune('X')
 	gb.AddRune('X')
 	gb.DebugPrint()
-	assert.Equal(t, 12, gb.gapStart())
-	assert.Equal(t, 24, gb.gapLen())
+	assert.Equal(t, 12, gb.Point)
+	assert.Equal(t, 24, gapOf(gb).gapLen())
 	gb.DebugPrint()
 	gb.SetPoint(0)
-	assert.Equal(t, 0, gb.gapStart())
-	assert.Equal(t, 24, gb.gapLen())
+	assert.Equal(t, 0, gb.Point)
+	assert.Equal(t, 24, gapOf(gb).gapLen())
 	gb.SetPoint(8)
-	assert.Equal(t, 8, gb.gapStart())
-	assert.Equal(t, 24, gb.gapLen())
+	assert.Equal(t, 8, gb.Point)
+	assert.Equal(t, 24, gapOf(gb).gapLen())
 	gb.DebugPrint()
 	gb.SetPoint(15)
-	assert.Equal(t, 15, gb.gapStart())
-	assert.Equal(t, 24, gb.gapLen())
+	assert.Equal(t, 15, gb.Point)
+	assert.Equal(t, 24, gapOf(gb).gapLen())
 	gb.SetPoint(10)
-	assert.Equal(t, 10, gb.gapStart())
-	assert.Equal(t, 24, gb.gapLen())
+	assert.Equal(t, 10, gb.Point)
+	assert.Equal(t, 24, gapOf(gb).gapLen())
 
 	gb.SetPoint(36)
-	assert.Equal(t, 36, gb.gapStart())
-	assert.Equal(t, 24, gb.gapLen())
+	assert.Equal(t, 36, gb.Point)
+	assert.Equal(t, 24, gapOf(gb).gapLen())
 	gb.SetPoint(23)
-	assert.Equal(t, 23, gb.gapStart())
-	assert.Equal(t, 24, gb.gapLen())
+	assert.Equal(t, 23, gb.Point)
+	assert.Equal(t, 24, gapOf(gb).gapLen())
 	gb.Backspace()
 	gb.Backspace()
 	gb.SetPoint(23)
-	assert.Equal(t, 23, gb.gapStart())
-	assert.Equal(t, 26, gb.gapLen())
+	assert.Equal(t, 23, gb.Point)
+	assert.Equal(t, 26, gapOf(gb).gapLen())
 	gb.SetPoint(23)
-	assert.Equal(t, 23, gb.gapStart())
-	assert.Equal(t, 26, gb.gapLen())
+	assert.Equal(t, 23, gb.Point)
+	assert.Equal(t, 26, gapOf(gb).gapLen())
 	gb.Insert("01234567890123456789")
-	assert.Equal(t, 43, gb.gapStart())
-	assert.Equal(t, 6, gb.gapLen())
+	assert.Equal(t, 43, gb.Point)
+	assert.Equal(t, 6, gapOf(gb).gapLen())
 	gb.SetPoint(gb.TextSize - 1)
-	assert.Equal(t, 58, gb.gapStart())
-	assert.Equal(t, 6, gb.gapLen())
+	assert.Equal(t, 58, gb.Point)
+	assert.Equal(t, 6, gapOf(gb).gapLen())
 	gb.SetPoint(0)
-	assert.Equal(t, 0, gb.gapStart())
-	assert.Equal(t, 6, gb.gapLen())
+	assert.Equal(t, 0, gb.Point)
+	assert.Equal(t, 6, gapOf(gb).gapLen())
 
 }
 
@@ -742,3 +747,58 @@ func TestDownDown(t *testing.T) {
 
 	//t.Error("end of test")
 }
+
+func TestNavigationAtEdges(t *testing.T) {
+	for _, s := range []string{"", "\n", "abc", "Lorem\nlite\n", "\n\nx"} {
+		gb := NewBuffer()
+		gb.setText(s)
+		size := gb.TextSize
+		for _, pt := range []int{-5, -1, 0, size - 1, size, size + 1, size + 50} {
+			assert.NotPanics(t, func() {
+				gb.SetPoint(pt)
+				gb.LineStart(pt)
+				gb.LineEnd(pt)
+				gb.LineLenAtPoint(pt)
+				gb.LineForPoint(pt)
+				gb.ColumnForPoint(pt)
+				gb.XYForPoint(pt)
+				gb.SegStart(pt, pt+3, 80)
+				gb.SegNext(pt, pt+3, 80)
+				gb.UpUp(pt, 80)
+				gb.DownDown(pt, 80)
+				gb.PointUp()
+				gb.PointDown()
+				gb.PointNext()
+				gb.PointPrevious()
+				gb.PointForLine(pt)
+				gb.getTextForLines(pt, pt+2)
+				gb.GetLineStats()
+			}, "text %q point %d", s, pt)
+			_, err := gb.RuneAt(pt)
+			assert.Equal(t, pt < 0 || pt >= size, err != nil, "RuneAt(%d) of %q", pt, s)
+		}
+	}
+}
+
+func TestGotoLine(t *testing.T) {
+	gb := NewBuffer()
+	gb.setText("Lorem\nlite\nsed ut\n")
+	assert.NoError(t, gb.gotoLine(2))
+	assert.Equal(t, 6, gb.Point)
+	assert.Error(t, gb.gotoLine(0))
+	assert.Equal(t, 0, gb.Point)
+	assert.Error(t, gb.gotoLine(42))
+	assert.Equal(t, gb.TextSize-1, gb.Point)
+}
+
+func TestGetRegion(t *testing.T) {
+	gb := NewBuffer()
+	gb.setText("Lorem\nlite\n")
+	s, err := gb.getRegion(3, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, "em\nli", s)
+	_, err = gb.getRegion(3, 80)
+	assert.Error(t, err)
+	_, err = gb.getRegion(-1, 3)
+	assert.Error(t, err)
+}
@@ -1,14 +1,38 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
 
 	"github.com/kristofer/ke/kg"
 )
 
 func main() {
-	argv := os.Args // array of filenames to edit
-	argc := len(argv)
+	logfile := flag.String("log", "", "write a debugging log to `file`")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [-log file] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// the log would scribble over the screen, so it goes to a file or nowhere
+	log.SetOutput(ioutil.Discard)
+	if *logfile != "" {
+		f, err := os.OpenFile(*logfile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "kg:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		log.SetOutput(f)
+	}
+
 	edit := &kg.Editor{}
-	edit.StartEditor(argv, argc)
+	if err := edit.RunTerminal(flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "kg:", err)
+		os.Exit(1)
+	}
 }
@@ -1,7 +1,6 @@
 package kg
 
 import (
-	"io/ioutil"
 	"log"
 	"strconv"
 	"strings"
@@ -12,7 +11,8 @@ func (e *Editor) quit() { e.Done = true }
 func (e *Editor) quitquit() {
 	e.EscapeFlag = false
 	e.CtrlXFlag = false
-	e.msg("Quit.\x07")
+	e.msg("Quit.")
+	e.beep()
 }
 func (e *Editor) up() {
 	e.CurrentBuffer.PointUp()
@@ -45,7 +45,7 @@ func (e *Editor) cut() {
 	e.copyCut(true)
 }
 func (e *Editor) resizeTerminal() {
-	e.CurrentWindow.OneWindow()
+	e.Resize(e.Term.Size())
 }
 
 func (e *Editor) quitAsk() {
@@ -63,7 +63,7 @@ func (e *Editor) yesno(flag bool, prompt string) bool {
 	e.DisplayMinibuffer(prompt, "")
 	e.MiniBufActive = true
 	defer func() { e.MiniBufActive = false }()
-	ev := <-e.InputChan
+	ev := e.nextEvent()
 	ch := ev.Ch
 	if ch == '\r' || ch == '\n' {
 		return flag
@@ -77,6 +77,7 @@ func (e *Editor) redraw() {
 	//termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
 	e.Term.SetCursor(0, 0)
 	e.Term.Clear()
+	e.Term.Repaint()
 	e.CurrentWindow.Updated = true
 	e.CurrentBuffer.Reframe = true
 	k := 0
@@ -96,13 +97,6 @@ func (e *Editor) right() {
 	e.CurrentBuffer.PointNext()
 }
 
-func (e *Editor) wleft() {
-
-}
-func (e *Editor) wright() {
-
-}
-
 func (e *Editor) pgdown() {
 	pt := e.CurrentBuffer.Point
 	l1 := e.CurrentBuffer.LineForPoint(e.CurrentBuffer.PageEnd)
@@ -136,8 +130,11 @@ func (e *Editor) gotoline() {
 	ln, err := strconv.Atoi(fname)
 	if err != nil {
 		e.msg("Invalid Line.")
+		return
+	}
+	if err := e.CurrentBuffer.gotoLine(ln); err != nil {
+		e.msg("Goto Line: %s", err)
 	}
-	e.CurrentBuffer.gotoLine(ln)
 }
 
 func (e *Editor) insertfile() {
@@ -150,13 +147,21 @@ func (e *Editor) insertfile() {
 	}
 }
 
+// paste inserts text pasted into the browser, as one change to undo
+func (e *Editor) paste(text string) {
+	bp := e.CurrentBuffer
+	bp.UndoBoundary()
+	bp.Insert(strings.ReplaceAll(text, "\r\n", "\n"))
+	e.lastCmd = cmdOther
+}
+
 func (e *Editor) readfile() {
 	fname := e.GetMinibufferInput("Find file: ")
 	if fname == "" {
 		e.msg("Nope")
 		return
 	}
-	dat, err := ioutil.ReadFile(fname)
+	dat, err := e.readFile(fname)
 	if err != nil {
 		e.msg("Failed to find file \"%s\".", fname)
 		return
@@ -220,9 +225,11 @@ func (e *Editor) toggleOverwriteMode() {
 func (e *Editor) killtoeol() {
 	bp := e.CurrentBuffer
 	pt := e.CurrentBuffer.Point
-	for i := 0; i < bp.LineLenAtPoint(pt)-bp.ColumnForPoint(pt); i++ {
-		bp.Delete()
+	n := bp.LineLenAtPoint(pt) - bp.ColumnForPoint(pt)
+	if n == 0 && pt < bp.TextSize {
+		n = 1 // at the end of the line, kill the newline
 	}
+	e.kill(pt, n)
 }
 
 func (e *Editor) copyCut(cut bool) {
@@ -240,32 +247,36 @@ func (e *Editor) copyCut(cut bool) {
 		extent = pt - bp.Mark
 		start = bp.Mark
 	}
-	scrap := make([]rune, extent)
-	l := start
-	for k := 0; k < extent; k++ {
-		rch, err := bp.RuneAt(l)
-		if err != nil {
-			e.msg("Copy/Cut failed. %s", err)
-		}
-		scrap[k] = rch
-		l++
+	scrap, err := bp.getRegion(start, start+extent)
+	if err != nil {
+		e.msg("Copy/Cut failed. %s", err)
+		bp.Mark = nomark
+		return
 	}
-	e.PasteBuffer = string(scrap)
 	if cut == true {
-		bp.Remove(start, extent)
+		e.kill(start, extent)
 		e.msg("%d characters cut.", extent)
 	} else {
+		e.Kills.push(scrap)
 		e.msg("%d bytes copied.", extent)
 	}
 	bp.Mark = nomark
 }
 
-func (e *Editor) paste() {
-	if len(e.PasteBuffer) <= 0 {
-		e.msg("PasteBuffer is empty.  Nothing to paste.")
-	} else {
-		e.CurrentBuffer.Insert(e.PasteBuffer)
+func (e *Editor) undo() {
+	if !e.CurrentBuffer.Undo() {
+		e.msg("No further undo information.")
+		return
+	}
+	e.msg("Undo!")
+}
+
+func (e *Editor) redo() {
+	if !e.CurrentBuffer.Redo() {
+		e.msg("No further redo information.")
+		return
 	}
+	e.msg("Redo!")
 }
 
 func (e *Editor) showpos() {
@@ -1,11 +1,15 @@
 package kg
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"runtime/debug"
 	"strings"
 	"syscall"
+	"time"
 	"unicode"
 
 	"github.com/gorilla/websocket"
@@ -13,12 +17,6 @@ import (
 	// "github.com/nsf/termbox-go"
 )
 
-func checkErr(e error) {
-	if e != nil {
-		panic(e)
-	}
-}
-
 const (
 	version        = "ke 2.0, Public Domain, May 2023, Kristofer Younger,  No warranty."
 	nomark         = -1
@@ -31,6 +29,7 @@ const (
 	idBlockComment = 6
 	idDoubleString = 7
 	idSingleString = 8
+	idKeyword      = 9
 	initialText    = "1 foo bar baz\n2 foo baz kristofer\n3 hello there.\n1234567890123456789012345678901234567890\n"
 )
 
@@ -39,16 +38,21 @@ type Editor struct {
 	Term      *term.Term
 	InputChan chan term.Event
 	//EventChan     chan termbox.Event
-	CurrentBuffer *Buffer /* current buffer */
-	RootBuffer    *Buffer /* head of list of buffers */
+	CurrentBuffer *Buffer     /* current buffer */
+	RootBuffer    *Buffer     /* head of list of buffers */
+	Storage       StorageKind /* kind of Storage for new buffers */
+	Root          string      /* directory files are kept to, "" for none */
 	CurrentWindow *Window
 	RootWindow    *Window
 	// status vars
-	Done          bool   /* Quit flag. */
-	Msgflag       bool   /* True if msgline should be displayed. */
-	PasteBuffer   string /* Allocated scrap buffer. */
-	Msgline       string /* Message line input/output buffer. */
+	Done          bool     /* Quit flag. */
+	Msgflag       bool     /* True if msgline should be displayed. */
+	Kills         KillRing /* killed and copied text */
+	Msgline       string   /* Message line input/output buffer. */
 	Searchtext    string
+	SearchRegexp  string
+	ExactCase     bool /* searches never fold case */
+	WholeWord     bool /* searches match whole words only */
 	Replace       string
 	Keymap        []keymapt
 	Lines         int
@@ -58,9 +62,19 @@ type Editor struct {
 	EscapeFlag    bool
 	CtrlXFlag     bool
 	MiniBufActive bool
+	lastCmd       cmdKind              /* what the previous command did */
+	thisCmd       cmdKind              /* what the running command did */
+	yankFrom      int                  /* start of the text the last yank inserted */
+	hiStart       int                  /* start of the highlighted search match */
+	hiEnd         int                  /* end of the highlighted search match */
+	unread        *term.Event          /* an event given back, to be read next */
+	attach        chan *websocket.Conn /* browsers taking over a web session */
+	stopped       chan struct{}        /* closed when a web session has ended */
 }
 
-// StartEditor is the old C main function
+// StartEditor runs an editing session for the web frontend on conn,
+// editing the first argc files of argv. When the editor quits, or conn goes
+// away, the session ends: conn is closed and quit is sent a signal.
 func (e *Editor) StartEditor(argv []string, argc int,
 	conn *websocket.Conn, quit chan os.Signal) {
 	// log setup....
@@ -74,97 +88,302 @@ func (e *Editor) StartEditor(argv []string, argc int,
 	// f.Truncate(0)
 	// log.Println("Start of Log...")
 	//
-	// e.FGColor = termbox.ColorDefault
-	// e.BGColor = termbox.ColorWhite
-	// err = termbox.Init()
-	//checkErr(err)
-	// defer termbox.Close()
-	// e.Cols, e.Lines = termbox.Size()
-
-	e.InputChan = make(chan term.Event, 20)
-	e.Term = term.NewTerm(term.Web)
-
-	e.Term.Kind = term.Web
-	e.Term.Conn = conn
-	e.Cols, e.Lines = e.Term.Size()
-
-	//editor.msg("NO file to open, creating scratch buffer")
-	e.CurrentBuffer = e.FindBuffer("*scratch*", true)
-	e.CurrentBuffer.Buffername = "*scratch*"
-	e.CurrentBuffer.setText(initialText)
-	//editor.top()
-
-	e.CurrentWindow = NewWindow(e)
-	e.RootWindow = e.CurrentWindow
-	e.CurrentWindow.OneWindow()
-	e.CurrentWindow.AssociateBuffer(e.CurrentBuffer)
-
-	if !(e.CurrentBuffer.GrowGap(16)) {
-		panic("%s: Failed to allocate required memory.\n")
+	e.StartSession(argv[:argc], quit)
+	if conn == nil {
+		return
 	}
-	e.Keymap = Keymap
+	go func() {
+		<-e.Attach(conn)
+		e.Stop()
+	}()
 
-	//m :=
-	e.UpdateDisplay()
-	e.Term.Flush()
+	log.Println("ending StartEditor")
+}
+
+// StartSession runs an editing session for the web frontend on files,
+// with no browser attached yet: see Attach. The session lasts until the
+// editor quits or is stopped, and then quit is sent a signal.
+func (e *Editor) StartSession(files []string, quit chan os.Signal) {
+	e.initEditor(term.NewTerm(term.Web), files)
+	e.attach = make(chan *websocket.Conn)
+	e.stopped = make(chan struct{})
 
 	go func() { // handle event loop
 		log.Println("starting handle event loop")
 		for {
-			event := <-e.InputChan
-			log.Println("DEqueue ", event.String())
-			log.Println("<- InputChan len ", len(e.InputChan))
-
+			event := e.event()
+			if event.Type == term.EventInterrupt {
+				break // the session is stopped
+			}
 			ok := e.HandleEvent(&event)
-			if !ok {
-				conn.Close()
+			if !ok || e.Done {
+				e.sayGoodbye()
 				break //exit editor
 			}
 
 			e.UpdateDisplay()
-
-			e.Term.Flush()
 		}
 		log.Println("ending event handle loop")
-		quit <- syscall.SIGINT
+		if e.Term.Conn != nil {
+			e.Term.Conn.Close()
+		}
+		close(e.stopped)
+		if quit != nil {
+			quit <- syscall.SIGINT
+		}
 	}()
+}
+
+// Attach connects a browser to the session on conn, in place of the one
+// before it, if any: the screen is sent to it whole and its keys go to the
+// editor. The channel given back is closed when conn has gone away.
+func (e *Editor) Attach(conn *websocket.Conn) <-chan struct{} {
+	gone := make(chan struct{})
+	select {
+	case e.attach <- conn:
+	case <-e.stopped:
+		conn.Close()
+		close(gone)
+		return gone
+	}
 	go func() {
 		log.Println("starting input loop")
+		defer close(gone)
 		for {
 			_, msg, err := conn.ReadMessage()
 			if err != nil {
 				log.Println("unable to get message from frontend")
 				return
 			}
-			log.Printf("ev: |%x| |%s| \n", msg, string(msg))
-
 			event := e.Term.EventFromKey(msg)
-			log.Println("queue event ", event.String())
+			if event.Type == term.EventNone {
+				continue
+			}
 
-			e.InputChan <- event
-			// for _, b := range msg {
-			// 	ev := e.Term.EventFromByte(b)
-			// 	log.Println("queue event ", ev.String())
-			// 	e.InputChan <- ev
-			// }
-			log.Println("InputChan <- len ", len(e.InputChan))
+			select {
+			case e.InputChan <- event:
+			case <-e.stopped:
+				return
+			}
 		}
-		log.Println("ending input loop")
 	}()
+	return gone
+}
 
-	log.Println("ending StartEditor")
+// Stop ends a web session as if its user had quit, also when the editor
+// is waiting for a key at a prompt, and returns once it has ended
+func (e *Editor) Stop() {
+	e.endSession(e.stopped)
+}
+
+// event waits for the next event, switching the screen over to a
+// browser that has been attached meanwhile
+func (e *Editor) event() term.Event {
+	if ev, ok := e.takeUnread(); ok {
+		return ev
+	}
+	for {
+		select {
+		case ev := <-e.InputChan:
+			return ev
+		case conn := <-e.attach:
+			if e.Term.Conn != nil && e.Term.Conn != conn {
+				e.Term.Conn.Close()
+			}
+			e.Term.Conn = conn
+			e.Term.Repaint()
+			e.Term.Flush()
+		}
+	}
+}
+
+// sayGoodbye tells the browser the editor has quit, rather than gone
+// away, so it does not try to connect to it again
+func (e *Editor) sayGoodbye() {
+	if e.Term.Conn == nil {
+		return
+	}
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "quit")
+	e.Term.Conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+// RunTerminal edits files in the terminal the program was started from.
+// It returns when the editor quits, giving the terminal back as it found
+// it, also when a signal ends the editor or a panic gets out of it.
+func (e *Editor) RunTerminal(files []string) error {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		return errors.New("standard input is not a terminal")
+	}
+	t := term.NewTerm(term.Pty)
+	defer t.Cleanup()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	e.initEditor(t, files)
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() { // input loop
+		for {
+			ev := t.PollEvent()
+			e.InputChan <- ev
+			if ev.Type == term.EventError {
+				e.endSession(stopped)
+				return
+			}
+		}
+	}()
+	for {
+		ev, ok := e.takeUnread()
+		if !ok {
+			select {
+			case sig := <-sigs:
+				return fmt.Errorf("ended by %s", sig)
+			case ev = <-e.InputChan:
+			}
+		}
+		switch ev.Type {
+		case term.EventError:
+			return ev.Err
+		case term.EventInterrupt:
+			return errors.New("input ended")
+		}
+		if !e.HandleEvent(&ev) || e.Done {
+			return nil
+		}
+		e.UpdateDisplay()
+	}
+}
+
+// unreadEvent gives ev back to the event loop, for a command that has read
+// a key it does not want: it ends the command, then runs as keys do
+func (e *Editor) unreadEvent(ev term.Event) {
+	e.unread = &ev
 }
 
-// handleEvent
-func (e *Editor) HandleEvent(ev *term.Event) bool {
+// takeUnread gives the event given back with unreadEvent, if there is one
+func (e *Editor) takeUnread() (term.Event, bool) {
+	if e.unread == nil {
+		return term.Event{}, false
+	}
+	ev := *e.unread
+	e.unread = nil
+	return ev, true
+}
+
+// endSession keeps telling the editor its input has gone, with
+// EventInterrupt, until its event loop has stopped, so that a command
+// waiting for a key at a prompt gives up as well
+func (e *Editor) endSession(stopped chan struct{}) {
+	for {
+		select {
+		case e.InputChan <- term.Event{Type: term.EventInterrupt}:
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// nextEvent waits for the next event, for a command that reads keys
+// itself. When the session is ending it gives C-g, so the command gives up.
+func (e *Editor) nextEvent() term.Event {
+	ev := e.event()
+	if ev.Type == term.EventInterrupt {
+		e.Done = true
+		return term.Event{Type: term.EventKey, Key: term.KeyCtrlG}
+	}
+	return ev
+}
+
+// initEditor sets the editor up on t with a window on the first of files,
+// or on a scratch buffer if there are none, and draws the screen
+func (e *Editor) initEditor(t *term.Term, files []string) {
+	e.FGColor = term.ColorDefault
+	e.BGColor = term.ColorWhite // modeline background
+	// err = termbox.Init()
+	//checkErr(err)
+	// defer termbox.Close()
+	// e.Cols, e.Lines = termbox.Size()
+
+	e.InputChan = make(chan term.Event, 20)
+	e.Term = t
+	e.Cols, e.Lines = e.Term.Size()
+
+	e.CurrentBuffer = e.loadFiles(files)
+	if e.CurrentBuffer == nil {
+		//editor.msg("NO file to open, creating scratch buffer")
+		e.CurrentBuffer = e.FindBuffer("*scratch*", true)
+		e.CurrentBuffer.Buffername = "*scratch*"
+		e.CurrentBuffer.setText(initialText)
+	}
+	//editor.top()
+
+	e.CurrentWindow = NewWindow(e)
+	e.RootWindow = e.CurrentWindow
+	e.CurrentWindow.OneWindow()
+	e.CurrentWindow.AssociateBuffer(e.CurrentBuffer)
+
+	e.Keymap = Keymap
+
+	//m :=
+	e.UpdateDisplay()
+}
+
+// loadFiles reads each of files into a buffer of its own and gives the
+// first of them, nil if there are none. A file that does not exist yet
+// gets an empty buffer, to be written when it is saved.
+func (e *Editor) loadFiles(files []string) *Buffer {
+	var first *Buffer
+	for _, fname := range files {
+		bp := e.FindBuffer(fname, true)
+		bp.Filename = fname
+		bp.Buffername = fname
+		dat, err := e.readFile(fname)
+		switch {
+		case err == nil:
+			bp.setText(string(dat))
+			e.msg("File \"%s\" %d bytes read.", fname, len(dat))
+		case os.IsNotExist(err):
+			e.msg("New file \"%s\".", fname)
+		default:
+			e.msg("Failed to read file \"%s\".", fname)
+		}
+		bp.modified = false
+		if first == nil {
+			first = bp
+		}
+	}
+	return first
+}
+
+// HandleEvent runs the command for one event. A panic in the command is
+// reported on the message line rather than ending the editing session.
+// The caller redraws the screen afterwards, with UpdateDisplay.
+func (e *Editor) HandleEvent(ev *term.Event) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("HandleEvent %s: %v\n%s", ev.String(), r, debug.Stack())
+			e.CtrlXFlag = false
+			e.EscapeFlag = false
+			e.msg("Error: %v", r)
+			ok = true
+		}
+	}()
 	e.msg("")
 	switch ev.Type {
 	case term.EventKey:
+		if ev.Mod&term.ModAlt != 0 {
+			// Alt (Meta) is the same as Esc before the key
+			e.EscapeFlag = true
+			ev.Mod &^= term.ModAlt
+		}
 		if ev.Ch >= 0 && ev.Ch <= 32 {
 			ok := e.OnSysKey(ev)
 			if !ok {
 				log.Println("no command found. 0")
 				e.msg("no command found. 0")
+				e.beep()
 			}
 			if e.Done {
 				return false
@@ -174,6 +393,7 @@ func (e *Editor) HandleEvent(ev *term.Event) bool {
 			if !ok {
 				log.Println("no command found. 1")
 				e.msg("no command found. 1")
+				e.beep()
 			}
 			if e.Done {
 				return false
@@ -183,6 +403,7 @@ func (e *Editor) HandleEvent(ev *term.Event) bool {
 			if !ok {
 				log.Println("no command found. 2")
 				e.msg("no command found. 2")
+				e.beep()
 			}
 			if e.Done {
 				return false
@@ -191,20 +412,27 @@ func (e *Editor) HandleEvent(ev *term.Event) bool {
 			//log.Println("e.CurrentWindow.OnKey", ev.String())
 			e.CurrentWindow.OnKey(ev)
 		}
-		e.UpdateDisplay()
 	case term.EventResize:
-		e.Term.Clear()
-		e.Cols, e.Lines = e.Term.Size()
+		e.Resize(ev.Width, ev.Height)
 		e.msg("Resize: h %d,w %d", e.Lines, e.Cols)
-		e.CurrentWindow.WindowResize()
-		e.UpdateDisplay()
 	case term.EventMouse:
-		e.Term.Clear()
-		e.msg("Mouse: r %d, c %d ", ev.MouseY, ev.MouseX)
-		e.SetPointForMouse(ev.MouseX, ev.MouseY)
-		e.UpdateDisplay()
+		switch ev.Key {
+		case term.MouseWheelUp:
+			for i := 0; i < 3; i++ {
+				e.up()
+			}
+		case term.MouseWheelDown:
+			for i := 0; i < 3; i++ {
+				e.down()
+			}
+		case term.MouseLeft, term.MouseMiddle, term.MouseRight:
+			e.SetPointForMouse(ev.MouseX, ev.MouseY)
+		}
+	case term.EventPaste:
+		e.paste(ev.Text)
 	case term.EventError:
-		panic(ev.Err)
+		log.Println("event error", ev.Err)
+		e.msg("Error: %s", ev.Err)
 	}
 
 	return true
@@ -220,6 +448,9 @@ func (e *Editor) OnSysKey(ev *term.Event) bool {
 		e.CtrlXFlag = true
 		return true
 	case term.KeyEsc:
+		if e.EscapeFlag {
+			return e.RunKeymapFunction(ev)
+		}
 		e.msg("Esc ")
 		e.EscapeFlag = true
 		return true
@@ -229,39 +460,67 @@ func (e *Editor) OnSysKey(ev *term.Event) bool {
 	case term.KeySpace, term.KeyEnter, term.KeyCtrlJ, term.KeyTab:
 		e.CurrentWindow.OnKey(ev)
 		return true
-	case term.KeyArrowDown, term.KeyArrowLeft, term.KeyArrowRight, term.KeyArrowUp:
-		e.CtrlXFlag = false
-		e.EscapeFlag = false
-		return e.RunKeymapFunction(ev)
 	default:
 		return e.RunKeymapFunction(ev)
 	}
 }
 
+// Resize makes the screen cols by lines and lays the windows out on it
+func (e *Editor) Resize(cols, lines int) {
+	if cols < 1 {
+		cols = 1
+	}
+	if lines < minWindowLines+1 {
+		lines = minWindowLines + 1
+	}
+	e.Term.Resize(cols, lines)
+	e.Term.Clear()
+	e.Cols, e.Lines = e.Term.Size()
+	e.layoutWindows()
+}
+
+// RunKeymapFunction runs the command bound to ev, after C-x or Esc if one
+// came before it. A key without a character is looked up by the sequence
+// an xterm sends for it, and if nothing is bound to it with its modifiers,
+// Ctrl+Left say, by the key on its own.
 func (e *Editor) RunKeymapFunction(ev *term.Event) bool {
 	rch := ev.Ch
 	if ev.Ch == 0 {
 		rch = rune(ev.Key)
 	}
-	lookfor := fmt.Sprintf("%c", rch)
+	keys := []string{fmt.Sprintf("%c", rch)}
+	if seq := term.KeySeq(ev.Key, ev.Mod); ev.Ch == 0 && seq != "" {
+		keys = []string{seq, term.KeySeq(ev.Key, 0)}
+	}
+	prefix := ""
 	if e.CtrlXFlag {
-		lookfor = fmt.Sprintf("\x18%c", rch)
+		prefix = "\x18"
 	}
 	if e.EscapeFlag {
-		lookfor = fmt.Sprintf("\x1B%c", rch)
-	}
-	for i, j := range e.Keymap {
-		if strings.Compare(lookfor, j.KeyBytes) == 0 {
-			//log.Println("SearchAndPerform FOUND ", lookfor, e.Keymap[i])
-			do := e.Keymap[i].Do
-			if do != nil {
-				do(e) // execute function for key
+		prefix = "\x1B"
+	}
+	for _, k := range keys {
+		lookfor := prefix + k
+		for i, j := range e.Keymap {
+			if strings.Compare(lookfor, j.KeyBytes) == 0 {
+				//log.Println("SearchAndPerform FOUND ", lookfor, e.Keymap[i])
+				do := e.Keymap[i].Do
+				e.CurrentBuffer.UndoBoundary()
+				e.thisCmd = cmdOther
+				if do != nil {
+					do(e) // execute function for key
+				}
+				// typing after the command is a step of its own
+				e.CurrentBuffer.UndoBoundary()
+				e.lastCmd = e.thisCmd
+				e.CtrlXFlag = false
+				e.EscapeFlag = false
+				return true
 			}
-			e.CtrlXFlag = false
-			e.EscapeFlag = false
-			return true
 		}
 	}
+	e.CtrlXFlag = false
+	e.EscapeFlag = false
 	return false
 }
 
@@ -322,6 +581,7 @@ func (e *Editor) Display(wp *Window, shouldDrawCursor bool) {
 	l2 := l1 + wp.Rows
 	l2end := bp.LineEnd(bp.PointForLine(l2))
 	bp.PageEnd = l2end
+	ids := bp.syntaxIDs(bp.PageStart, bp.PageEnd+1)
 	r, c := wp.TopPt, 0
 	for k := bp.PageStart; k <= bp.PageEnd; k++ {
 		if pt == k {
@@ -330,7 +590,15 @@ func (e *Editor) Display(wp *Window, shouldDrawCursor bool) {
 		}
 		rch, err := bp.RuneAt(k)
 		if err != nil {
-			e.msg("Error on RuneAt", err)
+			e.msg("Display: %s", err)
+			break
+		}
+		fg := e.FGColor
+		if i := k - bp.PageStart; i < len(ids) {
+			fg = e.syntaxColor(ids[i])
+		}
+		if k >= e.hiStart && k < e.hiEnd {
+			fg |= term.AttrReverse
 		}
 		if rch != '\r' {
 			if unicode.IsPrint(rch) || rch == '\t' || rch == '\n' {
@@ -338,13 +606,13 @@ func (e *Editor) Display(wp *Window, shouldDrawCursor bool) {
 					c += 3 //? 8-(j&7) : 1;
 				}
 				if rch != '\n' {
-					e.Term.SetCell(c, r, rch, e.FGColor, term.ColorDefault)
+					e.Term.SetCell(c, r, rch, fg, term.ColorDefault)
 					c++
 				} else {
 					//log.Println("found a newline,", r)
 				}
 			} else {
-				e.Term.SetCell(c, r, rch, e.FGColor, term.ColorDefault)
+				e.Term.SetCell(c, r, rch, fg, term.ColorDefault)
 				c++
 			}
 		}
@@ -369,7 +637,6 @@ func (e *Editor) Display(wp *Window, shouldDrawCursor bool) {
 		e.displayMsg()
 		e.setTermCursor(wp.Col, wp.Row) //bp.PointCol, bp.PointRow)
 	}
-	e.Term.Flush() //refresh();
 	wp.Updated = false
 }
 
@@ -396,14 +663,16 @@ func (e *Editor) setTermCursor(c, r int) {
 	e.Term.SetCursor(c, r)
 }
 
+// UpdateDisplay draws the windows that need it and sends the screen,
+// once, however many windows were drawn
 func (e *Editor) UpdateDisplay() {
 	bp := e.CurrentWindow.Buffer
 	bp.OrigPoint = bp.Point /* OrigPoint only ever set here */
 	/* only one window */
 	if e.RootWindow.Next == nil {
 		e.Display(e.CurrentWindow, true)
-		e.Term.Flush()
 		bp.PrevSize = bp.TextSize
+		e.flush()
 		return
 	}
 	/* this is key, we must call our win first to get accurate page and epage etc */
@@ -420,6 +689,31 @@ func (e *Editor) UpdateDisplay() {
 	e.displayMsg()
 	e.setTermCursor(e.CurrentWindow.Col, e.CurrentWindow.Row)
 	bp.PrevSize = bp.TextSize /* now safe to save previous size for next time */
+	e.flush()
+}
+
+// flush sends the screen, with a title naming the current buffer and the
+// message line for the status
+func (e *Editor) flush() {
+	bp := e.CurrentWindow.Buffer
+	mod := ""
+	if bp.modified {
+		mod = " (modified)"
+	}
+	e.Term.SetTitle(fmt.Sprintf("%s%s - kg", e.GetBufferName(bp), mod))
+	status := ""
+	if e.Msgflag {
+		status = e.Msgline
+	}
+	e.Term.SetStatus(status)
+	e.Term.Flush()
+}
+
+// beep rings the bell, if there is a terminal to ring it
+func (e *Editor) beep() {
+	if e.Term != nil {
+		e.Term.Bell()
+	}
 }
 
 // SetPointForMouse xxx
@@ -509,8 +803,7 @@ func (e *Editor) GetMinibufferInput(prompt string) string {
 	e.MiniBufActive = true
 	done := false
 	for !done {
-		ev = <-e.InputChan
-		log.Println("DEqueue minibuffer ", ev.String())
+		ev = e.nextEvent()
 		if ev.Ch != 0 {
 			ch := ev.Ch
 			fname = fname + string(ch)
@@ -622,7 +915,7 @@ func (e *Editor) FindBuffer(fname string, cflag bool) *Buffer {
 		bp = bp.Next
 	}
 	if cflag {
-		bp = NewBuffer()
+		bp = NewBufferStorage(e.Storage)
 		/* find the place in the list to insert this buffer */
 		if e.RootBuffer == nil {
 			e.RootBuffer = bp
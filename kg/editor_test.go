@@ -1,10 +1,115 @@
 package kg
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/kristofer/ke/term"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestEditor(t *testing.T) {
 	edit := &Editor{}
-	edit.StartEditor([]string{}, 0, nil)
+	edit.StartEditor([]string{}, 0, nil, nil)
+	assert.Equal(t, "*scratch*", edit.CurrentBuffer.Buffername)
+	assert.Equal(t, initialText, edit.CurrentBuffer.getText())
+	assert.Equal(t, edit.CurrentBuffer, edit.CurrentWindow.Buffer)
+}
+
+func TestLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.go")
+	assert.NoError(t, os.WriteFile(old, []byte("package old\n"), 0644))
+	fresh := filepath.Join(dir, "new.txt")
+
+	edit := &Editor{}
+	edit.StartEditor([]string{old, fresh}, 2, nil, nil)
+	assert.Equal(t, old, edit.CurrentBuffer.Filename)
+	assert.Equal(t, "package old\n", edit.CurrentBuffer.getText())
+	assert.False(t, edit.CurrentBuffer.modified)
+
+	bp := edit.FindBuffer(fresh, false)
+	if assert.NotNil(t, bp) {
+		assert.Equal(t, NewBuffer().getText(), bp.getText())
+		assert.Equal(t, fresh, bp.Filename)
+	}
+	assert.Equal(t, 2, edit.CountBuffers())
+	_, err := os.Stat(fresh)
+	assert.True(t, os.IsNotExist(err), "a new file is not written until it is saved")
+}
+
+func TestRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	assert.NoError(t, os.Mkdir(root, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "in.txt"), []byte("in\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "out.txt"), []byte("out\n"), 0644))
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "out.txt"), filepath.Join(root, "link")))
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "gone"), filepath.Join(root, "dangling")))
+
+	edit := &Editor{Root: root}
+	for _, fname := range []string{"in.txt", "/in.txt", "sub/../in.txt"} {
+		dat, err := edit.readFile(fname)
+		assert.NoError(t, err, fname)
+		assert.Equal(t, "in\n", string(dat), fname)
+	}
+	// ".." stops at the root, so this is root/out.txt, which is not there
+	_, err := edit.readFile("../out.txt")
+	assert.True(t, os.IsNotExist(err))
+	for _, fname := range []string{"link", "dangling"} {
+		_, err := edit.filePath(fname)
+		assert.Error(t, err, fname)
+	}
+	// a file that cannot be read leaves the buffer alone
+	edit.CurrentBuffer = NewBuffer()
+	edit.CurrentBuffer.setText("keep\n")
+	edit.CurrentBuffer.modified = true
+	for _, fname := range []string{"link", "missing.txt"} {
+		assert.False(t, edit.InsertFile(fname, false), fname)
+		assert.Equal(t, "keep\n", edit.CurrentBuffer.getText(), fname)
+		assert.True(t, edit.CurrentBuffer.modified, fname)
+	}
+	path, err := edit.filePath("new.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, "new.txt"), path)
+}
+
+// sendKeys decodes in as a terminal would and runs each key
+func sendKeys(e *Editor, in string) {
+	b := []byte(in)
+	for len(b) > 0 {
+		ev, n := term.ParseKey(b, false)
+		b = b[n:]
+		e.HandleEvent(&ev)
+	}
+}
+
+func TestKeySequences(t *testing.T) {
+	e := withTerm(newTestEditor("one two\nthree\n"))
+	bp := e.CurrentBuffer
+	sendKeys(e, "\x1b[B") // down
+	assert.Equal(t, 8, bp.Point)
+	sendKeys(e, "\x1b[F") // end
+	assert.Equal(t, 13, bp.Point)
+	sendKeys(e, "\x1b[1;5H") // ctrl+home
+	assert.Equal(t, 0, bp.Point)
+	sendKeys(e, "\x1b[1;5C") // ctrl+right
+	assert.Equal(t, 3, bp.Point)
+	sendKeys(e, "\x1b[1;2C") // shift+right, as right
+	assert.Equal(t, 4, bp.Point)
+	sendKeys(e, "\x1b[3~") // delete
+	assert.Equal(t, "one wo\nthree\n", bp.getText())
+	sendKeys(e, "\x1bb") // alt+b
+	assert.Equal(t, 0, bp.Point)
+	sendKeys(e, "\x1b\x1b[B") // alt+down
+	assert.Equal(t, bp.LineEnd(bp.TextSize), bp.Point)
+	assert.False(t, e.EscapeFlag)
+}
+
+func TestTerminalBackspace(t *testing.T) {
+	e := withTerm(newTestEditor("\n"))
+	// the bytes a terminal sends: DEL for Backspace, ESC DEL for M-Backspace
+	sendKeys(e, "ab\x7f foo bar\x1b\x7f")
+	assert.Equal(t, "a foo \n", e.CurrentBuffer.getText())
 }
@@ -1,7 +1,11 @@
 package kg
 
 import (
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"unicode"
 )
 
@@ -25,6 +29,46 @@ func (e *Editor) PosixFile(fname string) bool {
 	return true
 }
 
+// filePath gives the path of the file named fname. With a Root, fname is
+// taken from there, and may not lead out of it by ".." or a symbolic link.
+func (e *Editor) filePath(fname string) (string, error) {
+	if e.Root == "" {
+		return fname, nil
+	}
+	path := filepath.Join(e.Root, filepath.Clean("/"+fname))
+	root, err := filepath.EvalSymlinks(e.Root)
+	if err != nil {
+		return "", err
+	}
+	// a file not there yet is checked by the directory it would be in,
+	// but a link to nowhere could be written through
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if _, lerr := os.Lstat(path); lerr == nil {
+			return "", err
+		}
+		dir, derr := filepath.EvalSymlinks(filepath.Dir(path))
+		if derr != nil {
+			return "", err
+		}
+		real = filepath.Join(dir, filepath.Base(path))
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside %s", fname, e.Root)
+	}
+	return path, nil
+}
+
+// readFile reads the file named fname, from the Root if there is one
+func (e *Editor) readFile(fname string) ([]byte, error) {
+	path, err := e.filePath(fname)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
+
 // Save foo
 func (e *Editor) Save(fname string) bool {
 	if e.PosixFile(fname) != true {
@@ -39,7 +83,10 @@ func (e *Editor) Save(fname string) bool {
 			d1 = append(d1, '\n')
 		}
 	}
-	err := ioutil.WriteFile(fname, d1, 0644)
+	path, err := e.filePath(fname)
+	if err == nil {
+		err = ioutil.WriteFile(path, d1, 0644)
+	}
 	if err != nil {
 		e.msg("Failed to save file \"%s\".", fname)
 		return false
@@ -57,9 +104,10 @@ func (e *Editor) Save(fname string) bool {
 // InsertFile reads file into buffer at point
 func (e *Editor) InsertFile(fname string, modflag bool) bool {
 	bp := e.CurrentBuffer
-	dat, err := ioutil.ReadFile(fname)
+	dat, err := e.readFile(fname)
 	if err != nil {
 		e.msg("Failed to read and insert file \"%s\".", fname)
+		return false
 	}
 	if !modflag { // just do a load into buffer with no modification
 		bp.setText(string(dat))
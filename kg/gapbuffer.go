@@ -0,0 +1,224 @@
+package kg
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+ * gapBuffer is kg's original Storage: the runes sit in one slice with a gap
+ * at the last place edited, so typing and deleting there only touch the
+ * ends of the gap. The gap is moved to a point when an edit happens there.
+ */
+type gapBuffer struct {
+	data    []rune
+	gs      int // gap start, the point of the first rune after the gap
+	postLen int // runes after the gap
+	lines   lineIndex
+}
+
+func newGapBuffer(s string) *gapBuffer {
+	g := &gapBuffer{data: []rune(s)}
+	g.postLen = len(g.data)
+	g.lines.rebuild(g.data)
+	return g
+}
+
+func (g *gapBuffer) gapStart() int {
+	return g.gs
+}
+
+func (g *gapBuffer) gapLen() int {
+	return g.postStart() - g.gs
+}
+
+func (g *gapBuffer) postStart() int {
+	return len(g.data) - g.postLen
+}
+
+func (g *gapBuffer) Len() int {
+	return g.gs + g.postLen
+}
+
+func (g *gapBuffer) RuneAt(pt int) rune {
+	if pt < g.gs {
+		return g.data[pt]
+	}
+	return g.data[pt+g.gapLen()]
+}
+
+func (g *gapBuffer) Slice(from, to int) []rune {
+	ret := make([]rune, 0, to-from)
+	if from < g.gs {
+		e := to
+		if e > g.gs {
+			e = g.gs
+		}
+		ret = append(ret, g.data[from:e]...)
+		from = e
+	}
+	if from < to {
+		ret = append(ret, g.data[from+g.gapLen():to+g.gapLen()]...)
+	}
+	return ret
+}
+
+func (g *gapBuffer) Insert(pt int, rs []rune) {
+	g.moveGap(pt)
+	if g.gapLen() < len(rs) {
+		g.growGap(len(rs) + 2*gapchunk)
+	}
+	copy(g.data[g.gs:], rs)
+	for i, r := range rs {
+		if r == '\n' {
+			g.lines.pre = append(g.lines.pre, g.gs+i)
+		}
+	}
+	g.gs += len(rs)
+}
+
+func (g *gapBuffer) Delete(pt, n int) []rune {
+	g.moveGap(pt)
+	ps := g.postStart()
+	rs := make([]rune, n)
+	copy(rs, g.data[ps:ps+n])
+	for _, r := range rs {
+		// each one is the newline nearest the gap when it goes
+		if r == '\n' {
+			g.lines.post = g.lines.post[:len(g.lines.post)-1]
+		}
+	}
+	g.postLen -= n
+	return rs
+}
+
+func (g *gapBuffer) Lines() int {
+	return g.lines.count()
+}
+
+func (g *gapBuffer) LineStart(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	if n > g.lines.count() {
+		return g.Len()
+	}
+	return g.lines.nth(n, g.Len()) + 1
+}
+
+func (g *gapBuffer) LineFor(pt int) int {
+	return g.lines.before(pt, g.gs, g.Len())
+}
+
+// growGap makes the gap bigger by n
+func (g *gapBuffer) growGap(n int) {
+	newData := make([]rune, len(g.data)+n)
+	copy(newData, g.data[:g.gs])
+	copy(newData[g.postStart()+n:], g.data[g.postStart():])
+	g.data = newData
+}
+
+// moveGap moves the gap so that it starts at np, shifting the runes
+// (and the newlines in the index) that it passes over.
+func (g *gapBuffer) moveGap(np int) {
+	if np < 0 {
+		np = 0
+	}
+	size := g.Len()
+	if np > size {
+		np = size
+	}
+	li := &g.lines
+	if np < g.gs {
+		// move the runes [np, gs) to just before the post text
+		n := g.gs - np
+		copy(g.data[g.postStart()-n:], g.data[np:g.gs])
+		k := sort.SearchInts(li.pre, np)
+		for i := len(li.pre) - 1; i >= k; i-- {
+			li.post = append(li.post, size-li.pre[i])
+		}
+		li.pre = li.pre[:k]
+		g.gs = np
+		g.postLen += n
+	} else if np > g.gs {
+		// move the runes [gs, np) from after the gap to before it
+		n := np - g.gs
+		ps := g.postStart()
+		copy(g.data[g.gs:], g.data[ps:ps+n])
+		k := sort.SearchInts(li.post, size-np+1)
+		for i := len(li.post) - 1; i >= k; i-- {
+			li.pre = append(li.pre, size-li.post[i])
+		}
+		li.post = li.post[:k]
+		g.gs = np
+		g.postLen -= n
+	}
+}
+
+// debugPrint prints out the runes, showing the gap as @
+func (g *gapBuffer) debugPrint() {
+	for i := 0; i < len(g.data); i++ {
+		if i >= g.gs && i < g.postStart() {
+			fmt.Printf("@")
+		} else if g.data[i] == '\n' {
+			fmt.Printf("%c\n", 0x00B6)
+		} else {
+			fmt.Printf("%c", g.data[i])
+		}
+	}
+}
+
+/*
+ * lineIndex keeps the position of every '\n' in a gapBuffer so that line
+ * and point conversions are a binary search rather than a scan of the text.
+ * It is split at the gap just like the runes are:
+ *
+ *   pre  holds the points of the newlines before the gap, ascending.
+ *   post holds the newlines after the gap as their distance from the end
+ *        of the text, ascending, so the one nearest the gap is last.
+ *
+ * Typing or deleting at the gap only pushes or pops the end of one slice,
+ * and text after the gap never needs renumbering, as its distance from the
+ * end does not change. Moving the gap moves entries between the two slices.
+ */
+type lineIndex struct {
+	pre  []int
+	post []int
+}
+
+// rebuild indexes the newlines of rs, all of it being after the gap
+func (li *lineIndex) rebuild(rs []rune) {
+	li.pre = li.pre[:0]
+	li.post = li.post[:0]
+	for i := len(rs) - 1; i >= 0; i-- {
+		if rs[i] == '\n' {
+			li.post = append(li.post, len(rs)-i)
+		}
+	}
+}
+
+// count is the number of newlines in the text
+func (li *lineIndex) count() int {
+	return len(li.pre) + len(li.post)
+}
+
+// before counts the newlines at points < pt, in a text of size runes
+// with the gap at point gs
+func (li *lineIndex) before(pt, gs, size int) int {
+	if pt <= gs {
+		return sort.SearchInts(li.pre, pt)
+	}
+	// pt is after the gap: a newline at p is before pt when size-p > size-pt
+	return len(li.pre) + len(li.post) - sort.SearchInts(li.post, size-pt+1)
+}
+
+// nth returns the point of the n-th newline (origin = 1), or -1
+func (li *lineIndex) nth(n, size int) int {
+	if n <= 0 || n > li.count() {
+		return -1
+	}
+	if n <= len(li.pre) {
+		return li.pre[n-1]
+	}
+	return size - li.post[len(li.post)-(n-len(li.pre))]
+}
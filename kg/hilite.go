@@ -0,0 +1,165 @@
+package kg
+
+import "github.com/kristofer/ke/term"
+
+/*
+ * Each buffer with a language keeps a highlighter, which remembers the
+ * lexer state at the start of every line it has seen. An edit only throws
+ * away the states after the line it was on; when the lines below are lexed
+ * again and come back to the state they had before, the rest of the old
+ * states are taken as they are, so typing in a big file only relexes a line
+ * or two.
+ */
+
+// syntaxColors is the colour each token class is drawn in
+var syntaxColors = map[int]term.Attribute{
+	idDefault:      term.ColorDefault,
+	idSymbol:       term.ColorCyan,
+	idDigits:       term.ColorMagenta,
+	idLineComment:  term.ColorGreen,
+	idBlockComment: term.ColorGreen,
+	idDoubleString: term.ColorYellow,
+	idSingleString: term.ColorYellow,
+	idKeyword:      term.ColorBlue | term.AttrBold,
+}
+
+type highlighter struct {
+	lang    *language
+	fname   string // the file name lang was picked for
+	states  []int  // states[i] is the lexer state at the start of line i
+	valid   int    // states[:valid] are up to date
+	editEnd int    // states from here on are from before the last edits
+}
+
+// highlighter gives the buffer's highlighter, nil if it has no language
+func (bp *Buffer) highlighter() *highlighter {
+	if bp.hl == nil || bp.hl.fname != bp.Filename {
+		bp.hl = &highlighter{
+			lang:   languageFor(bp.Filename),
+			fname:  bp.Filename,
+			states: []int{0},
+			valid:  1,
+		}
+	}
+	if bp.hl.lang == nil {
+		return nil
+	}
+	return bp.hl
+}
+
+// textChanged tells the highlighter that at pt removed was taken out of
+// the text and inserted put in
+func (bp *Buffer) textChanged(pt int, removed, inserted []rune) {
+	if bp.hl == nil || bp.hl.lang == nil {
+		return
+	}
+	delta := 0
+	for _, r := range inserted {
+		if r == '\n' {
+			delta++
+		}
+	}
+	for _, r := range removed {
+		if r == '\n' {
+			delta--
+		}
+	}
+	bp.hl.edit(bp.text.LineFor(pt), delta)
+}
+
+// edit notes a change on line, which gained delta lines. The states of
+// the lines after the change are kept, moved to where those lines now are,
+// for stateAt to check.
+func (h *highlighter) edit(line, delta int) {
+	if h.valid > line+1 {
+		h.valid = line + 1
+	}
+	if h.editEnd > line {
+		h.editEnd += delta
+	}
+	end := line + 1
+	if delta > 0 {
+		end += delta
+	}
+	if end > h.editEnd {
+		h.editEnd = end
+	}
+	if line+1 >= len(h.states) {
+		return
+	}
+	if delta > 0 {
+		tail := append(make([]int, delta), h.states[line+1:]...)
+		h.states = append(h.states[:line+1], tail...)
+	} else if delta < 0 {
+		cut := line + 1 - delta
+		if cut > len(h.states) {
+			cut = len(h.states)
+		}
+		h.states = append(h.states[:line+1], h.states[cut:]...)
+	}
+}
+
+// stateAt gives the lexer state at the start of line
+func (h *highlighter) stateAt(bp *Buffer, line int) int {
+	for h.valid <= line {
+		l := h.valid - 1
+		_, next := h.lang.lex(bp.lineRunes(l), h.states[l])
+		if h.valid == len(h.states) {
+			h.states = append(h.states, next)
+			h.valid++
+			continue
+		}
+		old := h.states[h.valid]
+		h.states[h.valid] = next
+		h.valid++
+		if old == next && h.valid > h.editEnd {
+			// the rest of the lines are as they were
+			h.valid = len(h.states)
+		}
+	}
+	if h.valid == len(h.states) {
+		h.editEnd = 0
+	}
+	return h.states[line]
+}
+
+// lineRunes gives the runes of line, with its newline
+func (bp *Buffer) lineRunes(line int) []rune {
+	return bp.text.Slice(bp.text.LineStart(line), bp.text.LineStart(line+1))
+}
+
+// syntaxIDs gives the token class of each rune in [from, to), nil if the
+// buffer has no language
+func (bp *Buffer) syntaxIDs(from, to int) []int {
+	h := bp.highlighter()
+	if to > bp.TextSize {
+		to = bp.TextSize
+	}
+	if h == nil || from >= to {
+		return nil
+	}
+	ids := make([]int, 0, to-from)
+	line := bp.text.LineFor(from)
+	for pt := bp.text.LineStart(line); pt < to; line++ {
+		rs := bp.lineRunes(line)
+		if len(rs) == 0 {
+			break
+		}
+		lids, _ := h.lang.lex(rs, h.stateAt(bp, line))
+		for i := range lids {
+			if p := pt + i; p >= from && p < to {
+				ids = append(ids, lids[i])
+			}
+		}
+		pt += len(rs)
+	}
+	return ids
+}
+
+// syntaxColor is the colour to draw token class id in
+func (e *Editor) syntaxColor(id int) term.Attribute {
+	if c, ok := syntaxColors[id]; ok && c != term.ColorDefault {
+		return c
+	}
+	return e.FGColor
+}
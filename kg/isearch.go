@@ -0,0 +1,210 @@
+package kg
+
+import (
+	"github.com/kristofer/ke/term"
+)
+
+/*
+ * Incremental search. Every key typed at the search prompt extends the
+ * query and moves point to the match straight away. C-S and C-R go to the
+ * next match either way; when there is none, pressing them again wraps
+ * round the buffer. BACKSPACE steps back to how things were before the last
+ * key. ESC or RETURN leave point at the match, C-G puts it back where the
+ * search started, and any other command key ends the search and then runs.
+ * C-W switches whole word matching on and off.
+ */
+
+// isearchState is where the search stood after one key
+type isearchState struct {
+	query      []rune
+	start, end int // the current match, or the last one found when failing
+	forward    bool
+	failing    bool
+	wrapped    bool
+	opt        searchOpts
+}
+
+func (st *isearchState) prompt() string {
+	p := "I-search: "
+	if !st.forward {
+		p = "I-search backward: "
+	}
+	if st.opt.word {
+		p = "Word " + p
+	}
+	if st.wrapped {
+		p = "Wrapped " + p
+	}
+	if st.failing {
+		p = "Failing " + p
+	}
+	return p
+}
+
+// findForward returns the start of the first match of q at or after from,
+// or -1 if there is none
+func (bp *Buffer) findForward(from int, q []rune, opt searchOpts) int {
+	if from < 0 {
+		from = 0
+	}
+	end := bp.searchForward(from, string(q), opt)
+	if end == -1 {
+		return -1
+	}
+	return end - len(q)
+}
+
+// findBackward returns the start of the last match of q starting at or
+// before from, or -1 if there is none
+func (bp *Buffer) findBackward(from int, q []rune, opt searchOpts) int {
+	return bp.searchBackwards(from, string(q), opt)
+}
+
+// isearchNext looks for q from the match in st, giving the state after
+// the search
+func (e *Editor) isearchNext(st isearchState, q []rune, forward, again bool) isearchState {
+	bp := e.CurrentBuffer
+	n := st
+	n.query = q
+	n.forward = forward
+	n.opt = e.searchOpts(string(q))
+	if len(q) == 0 {
+		n.failing = false
+		return n
+	}
+	found := -1
+	switch {
+	case again && st.failing && forward:
+		found = bp.findForward(0, q, n.opt)
+		n.wrapped = true
+	case again && st.failing:
+		found = bp.findBackward(bp.TextSize, q, n.opt)
+		n.wrapped = true
+	case forward && again:
+		found = bp.findForward(st.start+1, q, n.opt)
+	case forward:
+		found = bp.findForward(st.start, q, n.opt)
+	case again:
+		found = bp.findBackward(st.start-1, q, n.opt)
+	default:
+		found = bp.findBackward(st.start, q, n.opt)
+	}
+	n.failing = found == -1
+	if !n.failing {
+		n.start, n.end = found, found+len(q)
+	} else if !st.failing {
+		e.beep()
+	}
+	return n
+}
+
+func (e *Editor) search() {
+	e.isearch(true)
+}
+
+func (e *Editor) rsearch() {
+	e.isearch(false)
+}
+
+func (e *Editor) isearch(forward bool) {
+	bp := e.CurrentBuffer
+	opoint := bp.Point
+	states := []isearchState{{start: opoint, end: opoint, forward: forward, opt: e.searchOpts("")}}
+	defer func() {
+		e.hiStart, e.hiEnd = 0, 0
+		e.MiniBufActive = false
+	}()
+	e.MiniBufActive = true
+	for {
+		st := states[len(states)-1]
+		e.showIsearch(&st, opoint)
+		ev := e.nextEvent()
+		q := st.query
+		extend := func(r rune) {
+			nq := append(append([]rune{}, q...), r)
+			states = append(states, e.isearchNext(st, nq, st.forward, false))
+		}
+		if ev.Type != term.EventKey {
+			continue
+		}
+		if ev.Ch != 0 && ev.Mod&term.ModAlt == 0 {
+			if ev.Ch == 0x7f {
+				ev.Key, ev.Ch = term.KeyBackspace2, 0
+			} else {
+				extend(ev.Ch)
+				continue
+			}
+		}
+		switch ev.Key {
+		case term.KeyCtrlS, term.KeyCtrlR:
+			fwd := ev.Key == term.KeyCtrlS
+			if len(q) == 0 {
+				// C-S C-S searches for the last thing searched for
+				q = []rune(e.Searchtext)
+				states = append(states, e.isearchNext(st, q, fwd, false))
+			} else {
+				states = append(states, e.isearchNext(st, q, fwd, true))
+			}
+			if n := states[len(states)-1]; n.wrapped && !st.wrapped && !n.failing {
+				e.msg("Wrapped")
+			}
+		case term.KeyCtrlW:
+			e.WholeWord = !e.WholeWord
+			// look again from where the search started
+			from := isearchState{start: opoint, end: opoint, wrapped: st.wrapped}
+			states = append(states, e.isearchNext(from, q, st.forward, false))
+		case term.KeySpace:
+			extend(' ')
+		case term.KeyTab:
+			extend('\t')
+		case term.KeyBackspace, term.KeyBackspace2:
+			if len(states) > 1 {
+				states = states[:len(states)-1]
+			}
+		case term.KeyCtrlG:
+			bp.SetPoint(opoint)
+			e.msg("Quit")
+			return
+		case term.KeyEsc, term.KeyEnter:
+			e.endIsearch(&st)
+			return
+		default:
+			// end the search here; the key is then run as any other
+			e.endIsearch(&st)
+			e.unreadEvent(ev)
+			return
+		}
+	}
+}
+
+// showIsearch moves point to the match of st, highlights it and
+// redraws, with the search prompt on the message line
+func (e *Editor) showIsearch(st *isearchState, opoint int) {
+	bp := e.CurrentBuffer
+	switch {
+	case len(st.query) == 0:
+		bp.SetPoint(opoint)
+		e.hiStart, e.hiEnd = 0, 0
+	case st.forward:
+		bp.SetPoint(st.end)
+	default:
+		bp.SetPoint(st.start)
+	}
+	if len(st.query) > 0 && !st.failing {
+		e.hiStart, e.hiEnd = st.start, st.end
+	}
+	if e.Term == nil || e.CurrentWindow == nil {
+		return
+	}
+	e.Display(e.CurrentWindow, true)
+	e.DisplayMinibuffer(st.prompt(), string(st.query))
+}
+
+func (e *Editor) endIsearch(st *isearchState) {
+	if len(st.query) > 0 {
+		e.Searchtext = string(st.query)
+	}
+	if st.failing {
+		e.msg("Failing %s%s", st.prompt()[len("Failing "):], string(st.query))
+	}
+}
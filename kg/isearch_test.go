@@ -0,0 +1,162 @@
+package kg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kristofer/ke/term"
+	"github.com/stretchr/testify/assert"
+)
+
+// typeKeys queues up keys for a modal command to read; runes are typed
+// as they are, term.Keys are sent as control keys and term.Events as given
+func typeKeys(e *Editor, keys ...interface{}) {
+	var evs []term.Event
+	for _, k := range keys {
+		switch k := k.(type) {
+		case rune:
+			evs = append(evs, term.Event{Type: term.EventKey, Ch: k})
+		case string:
+			for _, r := range k {
+				evs = append(evs, term.Event{Type: term.EventKey, Ch: r})
+			}
+		case term.Key:
+			evs = append(evs, term.Event{Type: term.EventKey, Key: k})
+		case term.Event:
+			evs = append(evs, k)
+		}
+	}
+	e.InputChan = make(chan term.Event, len(evs))
+	for _, ev := range evs {
+		e.InputChan <- ev
+	}
+}
+
+// runEvents handles the queued keys, and any a command gives back, as the
+// event loop does
+func runEvents(e *Editor) {
+	for e.unread != nil || len(e.InputChan) > 0 {
+		ev := e.event()
+		e.HandleEvent(&ev)
+	}
+}
+
+func TestIsearchForward(t *testing.T) {
+	e := newTestEditor("one foo two foo three\n")
+	bp := e.CurrentBuffer
+	typeKeys(e, "foo", term.KeyCtrlS, term.KeyEnter)
+	e.search()
+	assert.Equal(t, 15, bp.Point)
+	assert.Equal(t, "foo", e.Searchtext)
+	assert.Equal(t, 0, e.hiEnd)
+}
+
+func TestIsearchBackward(t *testing.T) {
+	e := newTestEditor("one foo two foo three\n")
+	bp := e.CurrentBuffer
+	bp.SetPoint(bp.TextSize)
+	typeKeys(e, "fo", term.KeyCtrlR, term.KeyEsc)
+	e.rsearch()
+	assert.Equal(t, 4, bp.Point)
+}
+
+func TestIsearchWraps(t *testing.T) {
+	e := newTestEditor("foo bar foo\n")
+	bp := e.CurrentBuffer
+	bp.SetPoint(5)
+	typeKeys(e, "foo", term.KeyCtrlS, term.KeyCtrlS, term.KeyEnter)
+	e.search()
+	assert.Equal(t, 3, bp.Point)
+	assert.Equal(t, "Wrapped", e.Msgline)
+}
+
+func TestIsearchBackspaceAndQuit(t *testing.T) {
+	e := newTestEditor("abc abd\n")
+	bp := e.CurrentBuffer
+	typeKeys(e, "abd", rune(0x7f), term.KeyEnter)
+	e.search()
+	assert.Equal(t, 2, bp.Point)
+
+	bp.SetPoint(1)
+	typeKeys(e, "abd", term.KeyCtrlG)
+	e.search()
+	assert.Equal(t, 1, bp.Point)
+	assert.Equal(t, "Quit", e.Msgline)
+}
+
+func TestIsearchLastSearch(t *testing.T) {
+	e := newTestEditor("xy xy xy\n")
+	e.Searchtext = "xy"
+	typeKeys(e, term.KeyCtrlS, term.KeyCtrlS, term.KeyEnter)
+	e.search()
+	assert.Equal(t, 5, e.CurrentBuffer.Point)
+}
+
+func TestIsearchOtherKeyRuns(t *testing.T) {
+	e := newTestEditor("one\ntwo\n")
+	typeKeys(e, term.KeyCtrlS, "tw", term.KeyCtrlE)
+	runEvents(e)
+	assert.Equal(t, 7, e.CurrentBuffer.Point)
+	assert.False(t, e.MiniBufActive)
+}
+
+func TestIsearchPrefixKeyRuns(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "foo.txt")
+	e := withTerm(newTestEditor("one foo\n"))
+	bp := e.CurrentBuffer
+	bp.Filename = fname
+	// C-x ends the search and starts C-x C-s, which saves
+	typeKeys(e, term.KeyCtrlS, "foo", term.KeyCtrlX, term.KeyCtrlS)
+	runEvents(e)
+	assert.Equal(t, 7, bp.Point)
+	dat, err := os.ReadFile(fname)
+	assert.NoError(t, err)
+	assert.Equal(t, "one foo\n", string(dat))
+
+	// Alt with a letter is a command, not part of the search
+	bp.SetPoint(0)
+	typeKeys(e, term.KeyCtrlS, "one", term.Event{Type: term.EventKey, Ch: 'b', Mod: term.ModAlt})
+	runEvents(e)
+	assert.Equal(t, "one", e.Searchtext)
+	assert.Equal(t, 0, bp.Point)
+}
+
+func TestIsearchSmartCase(t *testing.T) {
+	e := newTestEditor("An Error here, an error there\n")
+	bp := e.CurrentBuffer
+	typeKeys(e, "error", term.KeyEnter)
+	e.search()
+	assert.Equal(t, 8, bp.Point)
+
+	bp.SetPoint(0)
+	typeKeys(e, "an", term.KeyCtrlS, term.KeyEnter)
+	e.search()
+	assert.Equal(t, 17, bp.Point)
+
+	bp.SetPoint(10)
+	typeKeys(e, "Error", term.KeyCtrlS, term.KeyEnter) // fails, then wraps
+	e.search()
+	assert.Equal(t, 8, bp.Point)
+
+	e.ExactCase = true
+	bp.SetPoint(0)
+	typeKeys(e, "error", term.KeyEnter)
+	e.search()
+	assert.Equal(t, 23, bp.Point)
+}
+
+func TestIsearchWholeWord(t *testing.T) {
+	e := newTestEditor("errors and error\n")
+	bp := e.CurrentBuffer
+	typeKeys(e, term.KeyCtrlW, "error", term.KeyEnter)
+	e.search()
+	assert.Equal(t, 16, bp.Point)
+	assert.True(t, e.WholeWord)
+
+	bp.SetPoint(0)
+	typeKeys(e, "error", term.KeyCtrlW, term.KeyEnter)
+	e.search()
+	assert.Equal(t, 5, bp.Point)
+	assert.False(t, e.WholeWord)
+}
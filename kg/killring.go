@@ -0,0 +1,130 @@
+package kg
+
+/*
+ * The kill ring keeps the text of the last few kills and copies. C-y yanks
+ * the newest entry, and M-y straight after a yank swaps what was yanked for
+ * the entry before it, going round the ring.
+ * Kills made one straight after another (C-k C-k C-k) build up a single
+ * entry, so that a whole run of killed lines comes back with one C-y.
+ */
+
+const killRingSize = 60 // entries kept when KillRing.Size is not set
+
+// cmdKind is what the last command did, for kills and yanks that
+// behave differently when they follow one another
+type cmdKind int
+
+const (
+	cmdOther cmdKind = iota
+	cmdKill
+	cmdYank
+)
+
+// KillRing holds killed and copied text, newest last
+type KillRing struct {
+	Size    int // max entries kept
+	entries []string
+	yank    int // entry the last yank inserted
+}
+
+func (kr *KillRing) size() int {
+	if kr.Size <= 0 {
+		return killRingSize
+	}
+	return kr.Size
+}
+
+// Len is the number of entries in the ring
+func (kr *KillRing) Len() int {
+	return len(kr.entries)
+}
+
+// push adds s as the newest entry, dropping the oldest if the ring is full
+func (kr *KillRing) push(s string) {
+	kr.entries = append(kr.entries, s)
+	if n := kr.size(); len(kr.entries) > n {
+		kr.entries = kr.entries[len(kr.entries)-n:]
+	}
+	kr.yank = len(kr.entries) - 1
+}
+
+// join adds s to the newest entry, in front of it if before is true
+func (kr *KillRing) join(s string, before bool) {
+	if len(kr.entries) == 0 {
+		kr.push(s)
+		return
+	}
+	i := len(kr.entries) - 1
+	if before {
+		kr.entries[i] = s + kr.entries[i]
+	} else {
+		kr.entries[i] += s
+	}
+	kr.yank = i
+}
+
+// latest returns the newest entry, false if the ring is empty
+func (kr *KillRing) latest() (string, bool) {
+	if len(kr.entries) == 0 {
+		return "", false
+	}
+	kr.yank = len(kr.entries) - 1
+	return kr.entries[kr.yank], true
+}
+
+// previous returns the entry before the one last yanked, going round
+// to the newest after the oldest
+func (kr *KillRing) previous() (string, bool) {
+	if len(kr.entries) == 0 {
+		return "", false
+	}
+	kr.yank--
+	if kr.yank < 0 {
+		kr.yank = len(kr.entries) - 1
+	}
+	return kr.entries[kr.yank], true
+}
+
+// kill removes n runes at from and saves them on the kill ring. A kill
+// straight after another kill joins its entry; text killed backwards
+// from point goes in front.
+func (e *Editor) kill(from, n int) {
+	bp := e.CurrentBuffer
+	s, err := bp.getRegion(from, from+n)
+	if err != nil {
+		e.msg("Kill failed. %s", err)
+		return
+	}
+	if n > 0 {
+		if e.lastCmd == cmdKill {
+			e.Kills.join(s, from < bp.Point)
+		} else {
+			e.Kills.push(s)
+		}
+		bp.Remove(from, n)
+	}
+	e.thisCmd = cmdKill
+}
+
+func (e *Editor) yank() {
+	s, ok := e.Kills.latest()
+	if !ok {
+		e.msg("Kill ring is empty.  Nothing to yank.")
+		return
+	}
+	bp := e.CurrentBuffer
+	e.yankFrom = bp.Point
+	bp.Insert(s)
+	e.thisCmd = cmdYank
+}
+
+func (e *Editor) yankPop() {
+	if e.lastCmd != cmdYank {
+		e.msg("Previous command was not a yank")
+		return
+	}
+	s, _ := e.Kills.previous()
+	bp := e.CurrentBuffer
+	bp.Replace(e.yankFrom, bp.Point-e.yankFrom, s)
+	e.thisCmd = cmdYank
+}
@@ -0,0 +1,128 @@
+package kg
+
+import (
+	"testing"
+
+	"github.com/kristofer/ke/term"
+	"github.com/stretchr/testify/assert"
+)
+
+// ctrlKey runs the command bound to control key k
+func ctrlKey(e *Editor, k term.Key) bool {
+	return e.RunKeymapFunction(&term.Event{Type: term.EventKey, Key: k})
+}
+
+func TestKillLinesThenYank(t *testing.T) {
+	e := newTestEditor("one\ntwo\nthree\n")
+	bp := e.CurrentBuffer
+	for i := 0; i < 4; i++ {
+		assert.True(t, ctrlKey(e, term.KeyCtrlK))
+	}
+	assert.Equal(t, "three\n", bp.getText())
+	assert.Equal(t, 1, e.Kills.Len())
+	assert.True(t, ctrlKey(e, term.KeyCtrlY))
+	assert.Equal(t, "one\ntwo\nthree\n", bp.getText())
+	assert.Equal(t, 8, bp.Point)
+}
+
+func TestKillsNotConsecutive(t *testing.T) {
+	e := newTestEditor("one\ntwo\n")
+	bp := e.CurrentBuffer
+	ctrlKey(e, term.KeyCtrlK)
+	ctrlKey(e, term.KeyCtrlF) // a motion ends the run of kills
+	ctrlKey(e, term.KeyCtrlK)
+	assert.Equal(t, 2, e.Kills.Len())
+	assert.Equal(t, "\n\n", bp.getText())
+	ctrlKey(e, term.KeyCtrlY)
+	assert.Equal(t, "\ntwo\n", bp.getText())
+}
+
+func TestKillWordJoins(t *testing.T) {
+	e := newTestEditor("foo bar baz\n")
+	bp := e.CurrentBuffer
+	bp.SetPoint(7)
+	escKey(e, 0x7f)
+	escKey(e, 0x7f)
+	escKey(e, 'd')
+	assert.Equal(t, "\n", bp.getText())
+	assert.Equal(t, 1, e.Kills.Len())
+	ctrlKey(e, term.KeyCtrlY)
+	assert.Equal(t, "foo bar baz\n", bp.getText())
+}
+
+func TestCutCopyYankPop(t *testing.T) {
+	e := newTestEditor("alpha beta gamma\n")
+	bp := e.CurrentBuffer
+	bp.Mark = 0
+	bp.SetPoint(6)
+	escKey(e, 'w') // copy "alpha "
+	bp.Mark = 6
+	bp.SetPoint(11)
+	ctrlKey(e, term.KeyCtrlW) // cut "beta "
+	assert.Equal(t, "alpha gamma\n", bp.getText())
+	assert.Equal(t, 2, e.Kills.Len())
+
+	bp.SetPoint(bp.TextSize - 1)
+	ctrlKey(e, term.KeyCtrlY)
+	assert.Equal(t, "alpha gammabeta \n", bp.getText())
+	escKey(e, 'y')
+	assert.Equal(t, "alpha gammaalpha \n", bp.getText())
+	escKey(e, 'y') // round the ring again
+	assert.Equal(t, "alpha gammabeta \n", bp.getText())
+	assert.Equal(t, 16, bp.Point)
+
+	ctrlKey(e, term.KeyCtrlF)
+	escKey(e, 'y')
+	assert.Equal(t, "Previous command was not a yank", e.Msgline)
+	assert.Equal(t, "alpha gammabeta \n", bp.getText())
+}
+
+func TestKillRingSize(t *testing.T) {
+	e := newTestEditor("a\nb\nc\nd\n")
+	e.Kills.Size = 2
+	for i := 0; i < 4; i++ {
+		ctrlKey(e, term.KeyCtrlK)
+		ctrlKey(e, term.KeyCtrlK)
+		ctrlKey(e, term.KeyCtrlA)
+	}
+	assert.Equal(t, 2, e.Kills.Len())
+	ctrlKey(e, term.KeyCtrlY)
+	escKey(e, 'y')
+	escKey(e, 'y')
+	assert.Equal(t, "d\n", e.CurrentBuffer.getText())
+}
+
+func TestYankEmpty(t *testing.T) {
+	e := newTestEditor("abc\n")
+	ctrlKey(e, term.KeyCtrlY)
+	assert.Equal(t, "abc\n", e.CurrentBuffer.getText())
+	assert.Equal(t, "Kill ring is empty.  Nothing to yank.", e.Msgline)
+}
+
+func TestUndoTypingAfterKillAndYank(t *testing.T) {
+	e := withTerm(newTestEditor("one\ntwo\n"))
+	bp := e.CurrentBuffer
+	typeText := func(s string) {
+		for _, r := range s {
+			e.HandleEvent(&term.Event{Type: term.EventKey, Ch: r})
+		}
+	}
+
+	ctrlKey(e, term.KeyCtrlK)
+	typeText("xy")
+	assert.Equal(t, "xy\ntwo\n", bp.getText())
+	// the typing comes off first, then the kill
+	assert.True(t, bp.Undo())
+	assert.Equal(t, "\ntwo\n", bp.getText())
+	assert.True(t, bp.Undo())
+	assert.Equal(t, "one\ntwo\n", bp.getText())
+
+	bp.SetPoint(4)
+	ctrlKey(e, term.KeyCtrlY)
+	typeText("z")
+	assert.Equal(t, "one\noneztwo\n", bp.getText())
+	assert.True(t, bp.Undo())
+	assert.Equal(t, "one\nonetwo\n", bp.getText())
+	assert.True(t, bp.Undo())
+	assert.Equal(t, "one\ntwo\n", bp.getText())
+}
@@ -0,0 +1,145 @@
+package kg
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scanLineForPoint is LineForPoint done the slow way
+func scanLineForPoint(s []rune, point int) int {
+	if point >= len(s) {
+		point = len(s) - 1
+	}
+	line := 1
+	for i := 0; i < point; i++ {
+		if s[i] == '\n' {
+			line++
+		}
+	}
+	return line
+}
+
+// scanPointForLine is PointForLine done the slow way. Like it, a line
+// only counts once it has its newline, so the start of a last line with
+// no newline is not found and the end of the text is returned instead.
+func scanPointForLine(s []rune, ln int) int {
+	if ln <= 1 {
+		return 0
+	}
+	lines, start := 0, 0
+	for i, r := range s {
+		if r == '\n' {
+			lines++
+			if lines == ln {
+				return start
+			}
+			start = i + 1
+		}
+	}
+	return len(s) - 1
+}
+
+func TestLineIndexEdits(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, kind StorageKind) {
+		rnd := rand.New(rand.NewSource(1))
+		alphabet := []rune("ab\nσ\n")
+		gb := NewBufferStorage(kind)
+		gb.setText("one\ntwo\nthree\n")
+		for i := 0; i < 2000; i++ {
+			size := gb.TextSize
+			switch rnd.Intn(6) {
+			case 0:
+				gb.SetPoint(rnd.Intn(size + 1))
+			case 1:
+				gb.AddRune(alphabet[rnd.Intn(len(alphabet))])
+			case 2:
+				gb.Insert("x\ny\n")
+			case 3:
+				gb.Delete()
+			case 4:
+				gb.Backspace()
+			case 5:
+				gb.Remove(rnd.Intn(size+1), rnd.Intn(5))
+			}
+			if gb.TextSize == 0 {
+				gb.Insert("\n")
+			}
+			s := []rune(gb.getText())
+			pt := rnd.Intn(len(s) + 2)
+			ln := rnd.Intn(len(s)/2 + 3)
+			if !assert.Equal(t, scanLineForPoint(s, pt), gb.LineForPoint(pt), "LineForPoint(%d) of %q", pt, string(s)) ||
+				!assert.Equal(t, scanPointForLine(s, ln), gb.PointForLine(ln), "PointForLine(%d) of %q", ln, string(s)) {
+				return
+			}
+		}
+	})
+}
+
+func TestLineIndexUndo(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, kind StorageKind) {
+		gb := NewBufferStorage(kind)
+		gb.setText("aaa\nbbb\nccc\n")
+		gb.SetPoint(4)
+		gb.Insert("111\n222\n")
+		gb.UndoBoundary()
+		gb.Remove(0, 8)
+		assert.Equal(t, 4, gb.PointForLine(2))
+		gb.Undo()
+		gb.Undo()
+		assert.Equal(t, "aaa\nbbb\nccc\n", gb.getText())
+		assert.Equal(t, 4, gb.PointForLine(2))
+		assert.Equal(t, 8, gb.PointForLine(3))
+		assert.Equal(t, 3, gb.LineForPoint(9))
+	})
+}
+
+func bigBuffer(kind StorageKind, lines int) *Buffer {
+	var sb strings.Builder
+	for i := 0; i < lines; i++ {
+		sb.WriteString("the quick brown fox jumps over the lazy dog\n")
+	}
+	gb := NewBufferStorage(kind)
+	gb.setText(sb.String())
+	gb.SetPoint(gb.TextSize / 2)
+	return gb
+}
+
+func BenchmarkLineForPoint(b *testing.B) {
+	for _, kind := range storageKinds {
+		b.Run(kind.String(), func(b *testing.B) {
+			gb := bigBuffer(kind, 100000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				gb.LineForPoint(gb.TextSize - 1 - i%1000)
+			}
+		})
+	}
+}
+
+func BenchmarkPointForLine(b *testing.B) {
+	for _, kind := range storageKinds {
+		b.Run(kind.String(), func(b *testing.B) {
+			gb := bigBuffer(kind, 100000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				gb.PointForLine(99000 + i%1000)
+			}
+		})
+	}
+}
+
+func BenchmarkGetLineStats(b *testing.B) {
+	for _, kind := range storageKinds {
+		b.Run(kind.String(), func(b *testing.B) {
+			gb := bigBuffer(kind, 100000)
+			gb.SetPoint(gb.TextSize - 10)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				gb.GetLineStats()
+			}
+		})
+	}
+}
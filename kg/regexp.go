@@ -0,0 +1,215 @@
+package kg
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+ * Regular expression search and replace, using Go's regexp syntax.
+ * A search reads the buffer a rune at a time from where it starts, so
+ * it costs only as much text as it looks at. It starts a rune early so
+ * that ^, $ and \b see the text around point, and runs the regexp
+ * behind that one rune of context. Replacements may use \1 .. \9 for
+ * the groups of the match and \0 or \& for all of it.
+ */
+
+// reSearch is a regexp ready to search a buffer from any point
+type reSearch struct {
+	re       *regexp.Regexp // as typed, for a search from the start
+	next     *regexp.Regexp // the first match after a rune of context
+	here     *regexp.Regexp // a match at the start of the text
+	nextHere *regexp.Regexp // a match just after a rune of context
+}
+
+// compileSearch compiles the regexp s for searching a buffer
+func compileSearch(s string) (*reSearch, error) {
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, err
+	}
+	// the groups added do not capture, so the numbering is the same
+	rs := &reSearch{re: re}
+	for re, pattern := range map[**regexp.Regexp]string{
+		&rs.next:     `(?s:.)(?:` + s + `)`,
+		&rs.here:     `\A(?:` + s + `)`,
+		&rs.nextHere: `\A(?s:.)(?:` + s + `)`,
+	} {
+		if *re, err = regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+// reMatch is a match of a regexp in a buffer
+type reMatch struct {
+	start, end int    // points of the match
+	src        string // the buffer text that was read
+	loc        []int  // byte offsets in src of the match and its groups
+}
+
+// runeReader reads a buffer's runes from a point on, keeping the text read
+type runeReader struct {
+	bp   *Buffer
+	pt   int
+	read strings.Builder
+}
+
+func (r *runeReader) ReadRune() (rune, int, error) {
+	if r.pt >= r.bp.TextSize {
+		return 0, 0, io.EOF
+	}
+	ch := r.bp.text.RuneAt(r.pt)
+	r.pt++
+	if utf8.RuneLen(ch) < 0 {
+		ch = utf8.RuneError
+	}
+	r.read.WriteRune(ch)
+	return ch, utf8.RuneLen(ch), nil
+}
+
+// reFind runs re over the buffer text from point base on. With context,
+// the first rune read is not part of the match.
+func (bp *Buffer) reFind(re *regexp.Regexp, base int, context bool) (reMatch, bool) {
+	r := &runeReader{bp: bp, pt: base}
+	loc := re.FindReaderSubmatchIndex(r)
+	if loc == nil {
+		return reMatch{}, false
+	}
+	src := r.read.String()
+	if context {
+		_, size := utf8.DecodeRuneInString(src[loc[0]:])
+		loc[0] += size
+	}
+	start := base + utf8.RuneCountInString(src[:loc[0]])
+	end := start + utf8.RuneCountInString(src[loc[0]:loc[1]])
+	return reMatch{start, end, src, loc}, true
+}
+
+// reSearchForward finds the first match of rs starting at or after from
+func (bp *Buffer) reSearchForward(rs *reSearch, from int) (reMatch, bool) {
+	switch {
+	case from > bp.TextSize:
+		return reMatch{}, false
+	case from <= 0:
+		return bp.reFind(rs.re, 0, false)
+	}
+	return bp.reFind(rs.next, from-1, true)
+}
+
+// reSearchBackward finds the last match of rs starting at or before from,
+// trying each point in turn from there back
+func (bp *Buffer) reSearchBackward(rs *reSearch, from int) (reMatch, bool) {
+	if from > bp.TextSize {
+		from = bp.TextSize
+	}
+	for pt := from; pt > 0; pt-- {
+		if m, ok := bp.reFind(rs.nextHere, pt-1, true); ok {
+			return m, true
+		}
+	}
+	if from < 0 {
+		return reMatch{}, false
+	}
+	return bp.reFind(rs.here, 0, false)
+}
+
+// expand gives the replacement for m, with \N group references in
+// repl filled in
+func (m *reMatch) expand(search *reSearch, repl string) string {
+	var tmpl strings.Builder
+	rs := []rune(repl)
+	for i := 0; i < len(rs); i++ {
+		switch {
+		case rs[i] == '$':
+			tmpl.WriteString("$$")
+		case rs[i] == '\\' && i+1 < len(rs) && rs[i+1] >= '0' && rs[i+1] <= '9':
+			fmt.Fprintf(&tmpl, "${%c}", rs[i+1])
+			i++
+		case rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == '&':
+			tmpl.WriteString("${0}")
+			i++
+		case rs[i] == '\\' && i+1 < len(rs) && rs[i+1] == '\\':
+			tmpl.WriteRune('\\')
+			i++
+		default:
+			tmpl.WriteRune(rs[i])
+		}
+	}
+	return string(search.re.ExpandString(nil, tmpl.String(), m.src, m.loc))
+}
+
+// readRegexp prompts for a regexp, the last one used if none is typed
+func (e *Editor) readRegexp(prompt string) *reSearch {
+	if e.SearchRegexp != "" {
+		prompt = fmt.Sprintf("%s[%s] ", prompt, e.SearchRegexp)
+	}
+	s := e.GetMinibufferInput(prompt)
+	if s == "" {
+		s = e.SearchRegexp
+	}
+	if s == "" {
+		return nil
+	}
+	re, err := compileSearch(s)
+	if err != nil {
+		e.msg("Invalid regexp: %s", err)
+		return nil
+	}
+	e.SearchRegexp = s
+	return re
+}
+
+func (e *Editor) reSearchForward() {
+	re := e.readRegexp("RE search: ")
+	if re == nil {
+		return
+	}
+	bp := e.CurrentBuffer
+	m, ok := bp.reSearchForward(re, bp.Point)
+	if ok && m.end == bp.Point && m.start == m.end {
+		// an empty match at point, look past it
+		m, ok = bp.reSearchForward(re, bp.Point+1)
+	}
+	if !ok {
+		e.msg("Search failed: \"%s\"", e.SearchRegexp)
+		return
+	}
+	bp.SetPoint(m.end)
+}
+
+func (e *Editor) reSearchBackward() {
+	re := e.readRegexp("RE search backward: ")
+	if re == nil {
+		return
+	}
+	bp := e.CurrentBuffer
+	m, ok := bp.reSearchBackward(re, bp.Point-1)
+	if !ok {
+		e.msg("Search failed: \"%s\"", e.SearchRegexp)
+		return
+	}
+	bp.SetPoint(m.start)
+}
+
+/* search for a regexp and replace its matches, asking about each one */
+func (e *Editor) queryReplaceRegexp() {
+	re := e.readRegexp("Query replace regexp: ")
+	if re == nil {
+		return
+	}
+	e.Replace = e.GetMinibufferInput("With: ")
+	bp := e.CurrentBuffer
+	question := fmt.Sprintf("Replace '%s' with '%s' ? ", e.SearchRegexp, e.Replace)
+	e.replaceLoop(question, func(from int) (int, int, string, bool) {
+		m, ok := bp.reSearchForward(re, from)
+		if !ok {
+			return 0, 0, "", false
+		}
+		return m.start, m.end, m.expand(re, e.Replace), true
+	})
+}
@@ -0,0 +1,129 @@
+package kg
+
+import (
+	"testing"
+
+	"github.com/kristofer/ke/term"
+	"github.com/stretchr/testify/assert"
+)
+
+// withTerm gives e an offscreen terminal and a window on its buffer,
+// for commands that prompt and redisplay
+func withTerm(e *Editor) *Editor {
+	e.Term = term.NewTerm(term.Web)
+	e.Cols, e.Lines = e.Term.Size()
+	wp := NewWindow(e)
+	wp.OneWindow()
+	wp.AssociateBuffer(e.CurrentBuffer)
+	e.CurrentWindow, e.RootWindow = wp, wp
+	return e
+}
+
+func TestReSearchAcrossGap(t *testing.T) {
+	for _, kind := range storageKinds {
+		bp := NewBufferStorage(kind)
+		bp.setText("log.Printf(\"a\")\nfmt.Println(x)\n")
+		bp.SetPoint(16)
+		bp.Insert("// ") // leaves the gap inside the match below
+		bp.SetPoint(0)
+		re := mustCompileSearch(`f(mt)\.Print(ln)?`)
+		m, ok := bp.reSearchForward(re, 0)
+		assert.True(t, ok, kind.String())
+		assert.Equal(t, 19, m.start, kind.String())
+		assert.Equal(t, 30, m.end, kind.String())
+		assert.Equal(t, "<mt ln>", m.expand(re, `<\1 \2>`))
+
+		m, ok = bp.reSearchBackward(mustCompileSearch(`(?m)^\w+`), 18)
+		assert.True(t, ok)
+		assert.Equal(t, 0, m.start)
+		_, ok = bp.reSearchForward(re, 20)
+		assert.False(t, ok)
+	}
+}
+
+// mustCompileSearch is compileSearch for a regexp known to be good
+func mustCompileSearch(s string) *reSearch {
+	rs, err := compileSearch(s)
+	if err != nil {
+		panic(err)
+	}
+	return rs
+}
+
+func TestReSearchContext(t *testing.T) {
+	for _, tc := range []struct {
+		re, text string
+		from     int
+		forward  bool
+		start    int // -1 for no match
+		end      int
+	}{
+		// the text before from is there for ^ and \b to see
+		{`ab|^b`, "abb\n", 1, true, -1, 0},
+		{`(?m)^b`, "ab\nb\n", 1, true, 3, 4},
+		{`aa|\bab`, "xaab\n", 2, true, -1, 0},
+		{`\Bab`, "xaab\n", 2, true, 2, 4},
+		{`\bx`, "xx x\n", 1, true, 3, 4},
+		// a match running over from does not hide one starting there
+		{`aa`, "aaa\n", 1, true, 1, 3},
+		{`^a`, "aaa\n", 0, true, 0, 1},
+		// backward finds overlapping matches
+		{`aa`, "aaa\n", 2, false, 1, 3},
+		{`aa`, "aaa\n", 0, false, 0, 2},
+		{`\bb`, "ab b\n", 2, false, -1, 0},
+		{`\bb`, "ab b\n", 3, false, 3, 4},
+		{`a`, "bab\n", -1, false, -1, 0},
+	} {
+		bp := NewBuffer()
+		bp.setText(tc.text)
+		search := bp.reSearchForward
+		if !tc.forward {
+			search = bp.reSearchBackward
+		}
+		m, ok := search(mustCompileSearch(tc.re), tc.from)
+		if tc.start < 0 {
+			assert.False(t, ok, "%s in %q from %d: %v", tc.re, tc.text, tc.from, m)
+			continue
+		}
+		if assert.True(t, ok, "%s in %q from %d", tc.re, tc.text, tc.from) {
+			assert.Equal(t, [2]int{tc.start, tc.end}, [2]int{m.start, m.end},
+				"%s in %q from %d", tc.re, tc.text, tc.from)
+		}
+	}
+}
+
+func TestReSearchCommands(t *testing.T) {
+	e := withTerm(newTestEditor("αβ x1 y22 z333\n"))
+	bp := e.CurrentBuffer
+	typeKeys(e, `[a-z]\d+`, term.KeyEnter)
+	assert.True(t, escKey(e, rune(term.KeyCtrlS)))
+	assert.Equal(t, 5, bp.Point)
+	typeKeys(e, term.KeyEnter) // the same again
+	e.reSearchForward()
+	assert.Equal(t, 9, bp.Point)
+	typeKeys(e, term.KeyEnter)
+	e.reSearchBackward()
+	assert.Equal(t, 6, bp.Point)
+	typeKeys(e, "(", term.KeyEnter)
+	e.reSearchForward()
+	assert.Contains(t, e.Msgline, "Invalid regexp")
+}
+
+func TestQueryReplaceRegexp(t *testing.T) {
+	e := withTerm(newTestEditor("log(a, 1)\nlog(b, 2)\nlog(c, 3)\n"))
+	bp := e.CurrentBuffer
+	typeKeys(e, `log\((\w), (\d)\)`, term.KeyEnter, `\2 $= \1`, term.KeyEnter,
+		'n', 'y', '!')
+	e.queryReplaceRegexp()
+	assert.Equal(t, "log(a, 1)\n2 $= b\n3 $= c\n", bp.getText())
+	assert.Equal(t, "2 substitutions", e.Msgline)
+	bp.Undo()
+	assert.Equal(t, "log(a, 1)\nlog(b, 2)\nlog(c, 3)\n", bp.getText())
+}
+
+func TestQueryReplaceRegexpEmptyMatch(t *testing.T) {
+	e := withTerm(newTestEditor("abc\n"))
+	typeKeys(e, `x*`, term.KeyEnter, "-", term.KeyEnter, '!')
+	e.queryReplaceRegexp()
+	assert.Equal(t, "-a-b-c-\n-", e.CurrentBuffer.getText())
+}
@@ -1,6 +1,35 @@
 package kg
 
-import "fmt"
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/kristofer/ke/term"
+)
+
+/*
+ * Query replace goes through the matches after point one at a time,
+ * asking what to do with each:
+ *   y or SPACE  replace it and go on to the next
+ *   n or DEL    leave it and go on to the next
+ *   !           replace it and all the rest without asking
+ *   .           replace it and stop
+ *   ^           go back to the previous match, putting it back if it was replaced
+ *   u           undo the last replacement and ask about it again
+ *   q, ESC, RETURN or C-G  stop
+ */
+
+const replaceHelp = "(y)es, (n)o, (!)do the rest, (.)this and quit, (^)back, (u)ndo, (q)uit: "
+
+// qrStep is a match query replace has been past
+type qrStep struct {
+	start, end int // the match
+	repl       int // runes it was replaced with, -1 if it was left alone
+}
+
+// matchFunc finds the first match at or after from, giving the
+// text to replace it with
+type matchFunc func(from int) (start, end int, repl string, ok bool)
 
 /*search for a string and replace it with another string */
 func (e *Editor) queryReplace() {
@@ -9,69 +38,146 @@ func (e *Editor) queryReplace() {
 		return
 	}
 	e.Replace = e.GetMinibufferInput("With: ")
-	slen := len(e.Searchtext)
 	bp := e.CurrentBuffer
+	q := []rune(e.Searchtext)
+	opt := e.searchOpts(e.Searchtext)
+	question := fmt.Sprintf("Replace '%s' with '%s' ? ", e.Searchtext, e.Replace)
+	e.replaceLoop(question, func(from int) (int, int, string, bool) {
+		start := bp.findForward(from, q, opt)
+		if start == -1 {
+			return 0, 0, "", false
+		}
+		end := start + len(q)
+		repl := e.Replace
+		if opt.fold && !hasUpper(repl) {
+			// keep the case of what is replaced
+			matched, _ := bp.getRegion(start, end)
+			repl = matchCase(matched, repl)
+		}
+		return start, end, repl, true
+	})
+}
+
+// replaceLoop asks question about each match find gives, from point on,
+// and replaces the ones the user says yes to, as one undo step
+func (e *Editor) replaceLoop(question string, find matchFunc) {
+	bp := e.CurrentBuffer
+	bp.UndoBoundary()
+	defer bp.UndoBoundary()
 	opoint := bp.Point
-	lpoint := -1
 	ask := true
-	/* build query replace question string */
-	question := fmt.Sprintf("Replace '%s' with '%s' ? ", e.Searchtext, e.Replace)
-	/* scan through the file, from point */
 	numsub := 0
-outer:
-	for {
-		found := bp.searchForward(bp.Point, e.Searchtext)
-		/* if not found set the point to the last point of replacement, or where we started */
-		if found == -1 {
-			if lpoint == -1 {
-				bp.SetPoint(opoint)
-			} else {
-				bp.SetPoint(lpoint)
-			}
+	var steps []qrStep
+	defer func() { e.hiStart, e.hiEnd = 0, 0 }()
+	from := bp.Point
+loop:
+	for from <= bp.TextSize {
+		start, end, repl, ok := find(from)
+		if !ok {
 			break
 		}
-		bp.SetPoint(found)
-		/* search_forward places point at end of search, move to start of search */
-		for k := 0; k < slen; k++ {
-			bp.PointPrevious()
-		}
-		e.Display(e.CurrentWindow, true)
-
-		if ask == true {
-			answer := e.GetMinibufferInput(question)
-
-		inner:
-			for {
-				e.Display(e.CurrentWindow, true)
-				resp := []rune(answer)
-				c := ' '
-				if len(resp) > 0 {
-					c = resp[0]
+		last := false
+		if ask {
+			bp.SetPoint(end)
+			e.hiStart, e.hiEnd = start, end
+			switch e.replaceAnswer(question) {
+			case 'y':
+			case 'n':
+				steps = append(steps, qrStep{start, end, -1})
+				from = skipMatch(start, end)
+				continue
+			case '!':
+				ask = false
+			case '.':
+				last = true
+			case '^':
+				if len(steps) == 0 {
+					e.msg("No previous match")
+					continue
+				}
+				s := steps[len(steps)-1]
+				steps = steps[:len(steps)-1]
+				if s.repl >= 0 {
+					bp.undoChange()
+					numsub--
 				}
-				switch c {
-				case 'y': /* yes, substitute */
-					break inner
-				case 'n': /* no, find next */
-					bp.SetPoint(found) /* set to end of search string */
-				case '!': /* yes/stop asking, do the lot */
-					ask = false
-					break inner
-				//case 0x1B: /* esc */
-				//flushinp() /* discard any escape sequence without writing in buffer */
-				case 'q': /* controlled exit */
-					break outer
-				default: /* help me */
-					answer = e.GetMinibufferInput("(y)es, (n)o, (!)do the rest, (q)uit: ")
-					//continue inner
+				from = s.start
+				continue
+			case 'u':
+				i := len(steps) - 1
+				for i >= 0 && steps[i].repl < 0 {
+					i--
 				}
+				if i < 0 {
+					e.msg("Nothing to undo")
+					continue
+				}
+				bp.undoChange()
+				numsub--
+				from = steps[i].start
+				steps = steps[:i]
+				continue
+			default:
+				break loop
 			}
 		}
-		for k := 0; k < slen; k++ { // delete found search text
-			bp.Delete()
-		}
-		bp.Insert(e.Replace) // qed
-		lpoint = bp.Point
+		bp.Replace(start, end-start, repl)
+		n := utf8.RuneCountInString(repl)
+		steps = append(steps, qrStep{start, end, n})
 		numsub++
+		from = start + n
+		if start == end {
+			// step over an empty match so as not to replace it forever
+			from++
+		}
+		if last {
+			break
+		}
+	}
+	bp.SetPoint(opoint)
+	for i := len(steps) - 1; i >= 0; i-- {
+		if s := steps[i]; s.repl >= 0 {
+			bp.SetPoint(s.start + s.repl)
+			break
+		}
 	}
 	e.msg("%d substitutions", numsub)
 }
+
+// replaceAnswer shows the match and asks the question about it, giving
+// back one of y n ! . ^ u q
+func (e *Editor) replaceAnswer(question string) rune {
+	prompt := question
+	for {
+		e.Display(e.CurrentWindow, true)
+		e.DisplayMinibuffer(prompt, "")
+		ev := e.nextEvent()
+		if ev.Type != term.EventKey {
+			continue
+		}
+		switch ev.Ch {
+		case 'y', 'n', '!', '.', '^', 'u', 'q':
+			return ev.Ch
+		case 0x7f:
+			return 'n'
+		case 0:
+			switch ev.Key {
+			case term.KeySpace:
+				return 'y'
+			case term.KeyBackspace, term.KeyBackspace2:
+				return 'n'
+			case term.KeyEsc, term.KeyEnter, term.KeyCtrlG:
+				return 'q'
+			}
+		}
+		prompt = replaceHelp
+	}
+}
+
+// skipMatch is where to look for the match after the one at start, end
+func skipMatch(start, end int) int {
+	if start == end {
+		return end + 1
+	}
+	return end
+}
@@ -0,0 +1,83 @@
+package kg
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kristofer/ke/term"
+	"github.com/stretchr/testify/assert"
+)
+
+// queryReplace runs query replace of from with to, answering with keys
+func queryReplace(e *Editor, from, to string, keys ...interface{}) {
+	typeKeys(e, append([]interface{}{from, term.KeyEnter, to, term.KeyEnter}, keys...)...)
+	e.queryReplace()
+}
+
+func TestQueryReplaceMultibyte(t *testing.T) {
+	e := withTerm(newTestEditor("Grüße, ünd grüße αβγ grüße\n"))
+	bp := e.CurrentBuffer
+	queryReplace(e, "grüße", "ciao", 'y', 'n', 'y')
+	assert.Equal(t, "Ciao, ünd grüße αβγ ciao\n", bp.getText())
+	assert.Equal(t, "2 substitutions", e.Msgline)
+	assert.Equal(t, 24, bp.Point)
+}
+
+func TestQueryReplaceAnswers(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		keys []interface{}
+		want string
+		subs int
+	}{
+		{"yes", []interface{}{'y', 'y', 'y', 'y'}, "b b b b\n", 4},
+		{"space", []interface{}{term.KeySpace, 'n', term.KeySpace, 'q'}, "b a b a\n", 2},
+		{"no asks again", []interface{}{'n', 'n', 'n', 'y'}, "a a a b\n", 1},
+		{"del", []interface{}{rune(0x7f), 'y', 'q'}, "a b a a\n", 1},
+		{"all", []interface{}{'n', '!'}, "a b b b\n", 3},
+		{"dot", []interface{}{'n', '.'}, "a b a a\n", 1},
+		{"quit", []interface{}{'y', term.KeyEsc}, "b a a a\n", 1},
+		{"enter", []interface{}{term.KeyEnter}, "a a a a\n", 0},
+		{"help", []interface{}{'x', 'y', 'q'}, "b a a a\n", 1},
+		{"back", []interface{}{'y', 'y', '^', 'n', 'n', 'q'}, "b a a a\n", 1},
+		{"back over skipped", []interface{}{'n', '^', 'y', 'q'}, "b a a a\n", 1},
+		{"back at first", []interface{}{'^', 'y', 'q'}, "b a a a\n", 1},
+		{"undo", []interface{}{'y', 'n', 'u', 'n', 'y', 'q'}, "a b a a\n", 1},
+		{"undo nothing", []interface{}{'n', 'u', 'y', 'q'}, "a b a a\n", 1},
+	} {
+		e := withTerm(newTestEditor("a a a a\n"))
+		queryReplace(e, "a", "b", c.keys...)
+		assert.Equal(t, c.want, e.CurrentBuffer.getText(), c.name)
+		assert.Equal(t, c.subs, numSubs(e), c.name)
+	}
+}
+
+func numSubs(e *Editor) int {
+	n := -1
+	_, _ = fmt.Sscanf(e.Msgline, "%d substitutions", &n)
+	return n
+}
+
+func TestQueryReplaceNoMatch(t *testing.T) {
+	e := withTerm(newTestEditor("abc\n"))
+	e.CurrentBuffer.SetPoint(1)
+	queryReplace(e, "x", "y")
+	assert.Equal(t, "0 substitutions", e.Msgline)
+	assert.Equal(t, 1, e.CurrentBuffer.Point)
+	assert.Equal(t, 0, e.hiEnd)
+}
+
+func TestQueryReplaceUndo(t *testing.T) {
+	e := withTerm(newTestEditor("xü xü\n"))
+	bp := e.CurrentBuffer
+	bp.SetPoint(5)
+	bp.AddRune('!')
+	bp.SetPoint(0)
+	queryReplace(e, "ü", "üü", '!')
+	assert.Equal(t, "xüü xüü!\n", bp.getText())
+	// the whole run is one undo step, and the typing before it another
+	assert.True(t, bp.Undo())
+	assert.Equal(t, "xü xü!\n", bp.getText())
+	assert.True(t, bp.Undo())
+	assert.Equal(t, "xü xü\n", bp.getText())
+}
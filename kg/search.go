@@ -1,75 +1,155 @@
 package kg
 
-const (
-	fwdsearch = 1
-	revsearch = 2
-)
+import "unicode"
 
-func (e *Editor) search() {
-	e.Searchtext = e.GetMinibufferInput("Search: ")
-	found := e.CurrentBuffer.searchForward(e.CurrentBuffer.Point, e.Searchtext)
-	e.displaySearchResult(found, fwdsearch, "Search: ", e.Searchtext)
+/*
+ * Searches fold case unless the search string has an upper case letter
+ * in it (or Editor.ExactCase is set), so "error" finds "Error" and "ERROR"
+ * but "Error" finds only "Error". With Editor.WholeWord set a match must
+ * also be a whole word.
+ */
+
+// searchOpts are the ways a search string can match
+type searchOpts struct {
+	fold bool // ignore case
+	word bool // match whole words only
 }
-func (e *Editor) rsearch() {
-	e.Searchtext = e.GetMinibufferInput("R-Search: ")
-	found := e.CurrentBuffer.searchBackwards(e.CurrentBuffer.Point, e.Searchtext)
-	e.displaySearchResult(found, revsearch, "R-Search: ", e.Searchtext)
+
+func hasUpper(s string) bool {
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
 }
 
-func (e *Editor) displaySearchResult(found int, dir int, prompt string, search string) {
-	if found != -1 {
-		e.CurrentBuffer.SetPoint(found)
-		e.Display(e.CurrentWindow, true)
-	} else {
-		e.msg("Failing %s%s", prompt, search)
-		e.displayMsg()
+// searchOpts gives the options for searching for stext
+func (e *Editor) searchOpts(stext string) searchOpts {
+	return searchOpts{
+		fold: !e.ExactCase && !hasUpper(stext),
+		word: e.WholeWord,
+	}
+}
+
+// runeEq compares runes, folding case if fold is set
+func runeEq(a, b rune, fold bool) bool {
+	if a == b {
+		return true
+	}
+	if !fold {
+		return false
+	}
+	for f := unicode.SimpleFold(a); f != a; f = unicode.SimpleFold(f) {
+		if f == b {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAt reports whether s matches the text at p
+func (bp *Buffer) matchAt(p int, s []rune, opt searchOpts) bool {
+	if p < 0 || p+len(s) > bp.TextSize {
+		return false
+	}
+	for i, r := range s {
+		if !runeEq(bp.text.RuneAt(p+i), r, opt.fold) {
+			return false
+		}
+	}
+	if opt.word {
+		if p > 0 && isWordRune(bp.text.RuneAt(p-1)) {
+			return false
+		}
+		if end := p + len(s); end < bp.TextSize && isWordRune(bp.text.RuneAt(end)) {
+			return false
+		}
 	}
+	return true
 }
 
-func (bp *Buffer) searchForward(startp int, stext string) int {
-	endpt := bp.TextSize - 1
+// searchForward returns the point just past the first match of stext
+// starting at or after startp, or -1 if there is none
+func (bp *Buffer) searchForward(startp int, stext string, opt searchOpts) int {
 	if len(stext) == 0 {
 		return -1
 	}
-	for p := startp; p < endpt; p++ {
-		s := []rune(stext)
-		ss := 0
-		pp := 0
-		for pp = p; pp < endpt; pp++ {
-			rch, _ := bp.RuneAt(pp)
-			if ss < len(s) && s[ss] == rch {
-				ss++
-			} else {
-				break
-			}
-		}
-		if ss == len(s) {
-			return pp
+	s := []rune(stext)
+	if startp < 0 {
+		startp = 0
+	}
+	for p := startp; p+len(s) <= bp.TextSize; p++ {
+		if bp.matchAt(p, s, opt) {
+			return p + len(s)
 		}
 	}
 	return -1
 }
 
-func (bp *Buffer) searchBackwards(startp int, stext string) int {
-	endpt := bp.TextSize - 1
+// searchBackwards returns the point at the start of the last match of
+// stext starting at or before startp, or -1 if there is none
+func (bp *Buffer) searchBackwards(startp int, stext string, opt searchOpts) int {
 	if len(stext) == 0 {
-		return startp
+		return -1
+	}
+	s := []rune(stext)
+	if startp > bp.TextSize-len(s) {
+		startp = bp.TextSize - len(s)
 	}
 	for p := startp; p >= 0; p-- {
-		s := []rune(stext)
-		ss := 0
-		pp := 0
-		for pp = p; pp < endpt; pp++ {
-			rch, _ := bp.RuneAt(pp)
-			if ss < len(s) && s[ss] == rch {
-				ss++
-			} else {
-				break
+		if bp.matchAt(p, s, opt) {
+			return p
+		}
+	}
+	return -1
+}
+
+// matchCase makes repl follow the case of the text it replaces, when
+// that is all upper case or capitalized
+func matchCase(matched, repl string) string {
+	letters, upper, caps := 0, 0, true
+	inWord := false
+	for _, r := range matched {
+		switch {
+		case !isWordRune(r):
+			inWord = false
+		case !inWord:
+			inWord = true
+			caps = caps && !unicode.IsLower(r)
+		}
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
 			}
 		}
-		if ss == len(s) {
-			return pp
+	}
+	switch {
+	case letters == 0 || upper == 0:
+		return repl
+	case upper == letters && letters > 1:
+		return string(mapRunes(repl, unicode.ToUpper, unicode.ToUpper))
+	case caps:
+		return string(mapRunes(repl, unicode.ToTitle, unicode.ToLower))
+	}
+	return repl
+}
+
+// mapRunes maps the first rune of each word in s by first and the rest by f
+func mapRunes(s string, first, f func(rune) rune) []rune {
+	rs := []rune(s)
+	inWord := false
+	for i, r := range rs {
+		switch {
+		case !isWordRune(r):
+			inWord = false
+		case !inWord:
+			inWord = true
+			rs[i] = first(r)
+		default:
+			rs[i] = f(r)
 		}
 	}
-	return -1
+	return rs
 }
@@ -0,0 +1,53 @@
+package kg
+
+import (
+	"testing"
+
+	"github.com/kristofer/ke/term"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuneEq(t *testing.T) {
+	assert.True(t, runeEq('a', 'A', true))
+	assert.False(t, runeEq('a', 'A', false))
+	assert.True(t, runeEq('σ', 'Σ', true))
+	assert.True(t, runeEq('ς', 'Σ', true))
+	assert.True(t, runeEq('k', 'K', true)) // Kelvin sign
+	assert.False(t, runeEq('a', 'b', true))
+}
+
+func TestMatchCase(t *testing.T) {
+	for _, c := range []struct{ matched, repl, want string }{
+		{"error", "fault", "fault"},
+		{"Error", "fault", "Fault"},
+		{"ERROR", "fault", "FAULT"},
+		{"eRRor", "fault", "fault"},
+		{"Bad Error", "worse fault", "Worse Fault"},
+		{"E", "fault", "Fault"},
+		{"42", "fault", "fault"},
+	} {
+		assert.Equal(t, c.want, matchCase(c.matched, c.repl), c.matched)
+	}
+}
+
+func TestSearchWholeWord(t *testing.T) {
+	bp := NewBuffer()
+	bp.setText("foo food _foo foo\n")
+	opt := searchOpts{word: true}
+	assert.Equal(t, 3, bp.searchForward(0, "foo", opt))
+	assert.Equal(t, 13, bp.searchForward(1, "foo", opt))
+	assert.Equal(t, 17, bp.searchForward(14, "foo", opt))
+	assert.Equal(t, 10, bp.searchBackwards(13, "foo", opt))
+	assert.Equal(t, 14, bp.searchBackwards(bp.TextSize, "foo", opt))
+	assert.Equal(t, -1, bp.searchBackwards(9, "", opt))
+	assert.Equal(t, -1, bp.searchForward(0, "fo", opt))
+}
+
+func TestQueryReplaceKeepsCase(t *testing.T) {
+	e := withTerm(newTestEditor("error Error ERROR errors\n"))
+	e.WholeWord = true
+	typeKeys(e, "error", term.KeyEnter, "fault", term.KeyEnter, '!')
+	e.queryReplace()
+	assert.Equal(t, "fault Fault FAULT errors\n", e.CurrentBuffer.getText())
+	assert.Equal(t, "3 substitutions", e.Msgline)
+}
@@ -0,0 +1,58 @@
+package kg
+
+/*
+ * Storage is what a Buffer keeps its text in. The Buffer owns point, mark,
+ * undo and the display state; everything about how the runes are held is
+ * behind this interface, so that the gap buffer and the buffer.Table piece
+ * table can be swapped per buffer.
+ *
+ * Points are rune offsets from the start of the text. Callers (the Buffer)
+ * keep them inside the text, so implementations need not check them.
+ */
+type Storage interface {
+	// Len is the number of runes in the text
+	Len() int
+	// RuneAt returns the rune at pt, 0 <= pt < Len()
+	RuneAt(pt int) rune
+	// Slice returns a copy of the runes in [from, to)
+	Slice(from, to int) []rune
+	// Insert puts rs into the text at pt, 0 <= pt <= Len()
+	Insert(pt int, rs []rune)
+	// Delete removes n runes starting at pt and returns them
+	Delete(pt, n int) []rune
+	// Lines is the number of '\n' in the text
+	Lines() int
+	// LineStart returns the point just after the n-th '\n' (0 for n = 0),
+	// or Len() if there are fewer than n
+	LineStart(n int) int
+	// LineFor returns the number of '\n' before pt
+	LineFor(pt int) int
+}
+
+// StorageKind picks the Storage used for a buffer's text
+type StorageKind int
+
+const (
+	// GapStorage is a gap buffer, cheap to edit around the cursor
+	GapStorage StorageKind = iota
+	// TableStorage is a buffer.Table piece table
+	TableStorage
+)
+
+func (k StorageKind) String() string {
+	switch k {
+	case GapStorage:
+		return "gap"
+	case TableStorage:
+		return "table"
+	}
+	return "unknown"
+}
+
+// newStorage makes a Storage of kind holding s
+func newStorage(kind StorageKind, s string) Storage {
+	if kind == TableStorage {
+		return newTableStorage(s)
+	}
+	return newGapBuffer(s)
+}
@@ -0,0 +1,122 @@
+package kg
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// every Storage must pass the conformance tests below
+var storageKinds = []StorageKind{GapStorage, TableStorage}
+
+func forEachStorage(t *testing.T, f func(t *testing.T, kind StorageKind)) {
+	for _, kind := range storageKinds {
+		kind := kind
+		t.Run(kind.String(), func(t *testing.T) { f(t, kind) })
+	}
+}
+
+// checkStorage compares everything s can tell about its text with want
+func checkStorage(t *testing.T, want []rune, s Storage) bool {
+	ok := assert.Equal(t, len(want), s.Len(), "Len") &&
+		assert.Equal(t, string(want), string(s.Slice(0, s.Len())), "Slice")
+	lines := 0
+	for i, r := range want {
+		ok = ok && assert.Equal(t, r, s.RuneAt(i), "RuneAt(%d)", i) &&
+			assert.Equal(t, lines, s.LineFor(i), "LineFor(%d)", i)
+		if r == '\n' {
+			lines++
+			ok = ok && assert.Equal(t, i+1, s.LineStart(lines), "LineStart(%d)", lines)
+		}
+	}
+	return ok &&
+		assert.Equal(t, lines, s.Lines(), "Lines") &&
+		assert.Equal(t, lines, s.LineFor(len(want)), "LineFor(Len)") &&
+		assert.Equal(t, 0, s.LineStart(0), "LineStart(0)") &&
+		assert.Equal(t, len(want), s.LineStart(lines+1), "LineStart past the end")
+}
+
+func TestStorageNew(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, kind StorageKind) {
+		for _, text := range []string{"", "\n", "abc", "Lorem\nlite\nsed ut\n", "Οὐχὶ ταὐτὰ\nπαρίσταταί\n", "\n\n\nx"} {
+			checkStorage(t, []rune(text), newStorage(kind, text))
+		}
+	})
+}
+
+func TestStorageInsert(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, kind StorageKind) {
+		s := newStorage(kind, "Lorem\nlite\n")
+		s.Insert(0, []rune("foo"))
+		s.Insert(s.Len(), []rune("end\n"))
+		s.Insert(5, []rune("μέσο\n"))
+		s.Insert(5, []rune{})
+		checkStorage(t, []rune("fooLoμέσο\nrem\nlite\nend\n"), s)
+		assert.Equal(t, "μέσο", string(s.Slice(5, 9)))
+	})
+}
+
+func TestStorageDelete(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, kind StorageKind) {
+		s := newStorage(kind, "Lorem\nlite\nsed ut\n")
+		assert.Equal(t, "m\nli", string(s.Delete(4, 4)))
+		checkStorage(t, []rune("Lorete\nsed ut\n"), s)
+		assert.Equal(t, "Lo", string(s.Delete(0, 2)))
+		assert.Equal(t, "ut\n", string(s.Delete(s.Len()-3, 3)))
+		checkStorage(t, []rune("rete\nsed "), s)
+		assert.Equal(t, "", string(s.Delete(3, 0)))
+		s.Delete(0, s.Len())
+		checkStorage(t, []rune{}, s)
+		s.Insert(0, []rune("again\n"))
+		checkStorage(t, []rune("again\n"), s)
+	})
+}
+
+// TestStorageModel makes random edits to a Storage and to a plain
+// []rune and checks that they always agree.
+func TestStorageModel(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, kind StorageKind) {
+		rnd := rand.New(rand.NewSource(7))
+		alphabet := []rune("ab\nσ\n")
+		model := []rune("one\ntwo\nthree\n")
+		s := newStorage(kind, string(model))
+		for i := 0; i < 500; i++ {
+			pt := rnd.Intn(len(model) + 1)
+			if rnd.Intn(2) == 0 {
+				rs := make([]rune, rnd.Intn(4))
+				for j := range rs {
+					rs[j] = alphabet[rnd.Intn(len(alphabet))]
+				}
+				s.Insert(pt, rs)
+				model = append(model[:pt], append(rs, model[pt:]...)...)
+			} else {
+				n := rnd.Intn(len(model) - pt + 1)
+				want := string(model[pt : pt+n])
+				if !assert.Equal(t, want, string(s.Delete(pt, n))) {
+					return
+				}
+				model = append(model[:pt], model[pt+n:]...)
+			}
+			if !checkStorage(t, model, s) {
+				return
+			}
+		}
+	})
+}
+
+func TestBufferSetStorage(t *testing.T) {
+	gb := NewBuffer()
+	gb.setText("Lorem\nlite\n")
+	gb.SetPoint(3)
+	gb.Insert("foo")
+	gb.SetStorage(TableStorage)
+	assert.Equal(t, TableStorage, gb.Storage())
+	assert.Equal(t, "Lorfooem\nlite\n", gb.getText())
+	assert.Equal(t, 6, gb.Point)
+	gb.Insert("bar")
+	assert.Equal(t, "Lorfoobarem\nlite\n", gb.getText())
+	gb.Undo()
+	gb.Undo()
+	assert.Equal(t, "Lorem\nlite\n", gb.getText())
+}
@@ -0,0 +1,211 @@
+package kg
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+/*
+ * Languages for syntax highlighting. Each line of text is split into
+ * tokens of the idDefault .. idKeyword classes by lex. A line can end
+ * inside a block (a block comment, a Go raw string, a Markdown code fence)
+ * which carries on into the lines after it; which block that is, if any,
+ * is the state lex is given at the start of each line and gives back at
+ * the end.
+ */
+
+// block is a token that can run over several lines
+type block struct {
+	open, close string
+	id          int
+}
+
+type language struct {
+	name        string
+	exts        []string // file name extensions, with the dot
+	names       []string // whole file names
+	lineComment string
+	afterSpace  bool    // line comments only start at the start of a word
+	blocks      []block // state i+1 is inside blocks[i]
+	quotes      string  // one line strings, '"' for idDoubleString and the rest idSingleString
+	headings    bool    // lines starting with '#' are headings, shown as keywords
+	symbols     string
+	keywords    map[string]bool
+}
+
+func keywords(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var languages = []*language{
+	{
+		name:        "Go",
+		exts:        []string{".go"},
+		lineComment: "//",
+		blocks: []block{
+			{"/*", "*/", idBlockComment},
+			{"`", "`", idDoubleString},
+		},
+		quotes:  "\"'",
+		symbols: "+-*/%&|^<>=!:;,.(){}[]",
+		keywords: keywords(`break case chan const continue default defer else
+			fallthrough for func go goto if import interface map package range
+			return select struct switch type var true false nil iota`),
+	},
+	{
+		name:     "Markdown",
+		exts:     []string{".md", ".markdown"},
+		blocks:   []block{{"```", "```", idBlockComment}},
+		quotes:   "`",
+		headings: true,
+		symbols:  "*_>[]()!",
+	},
+	{
+		name:        "Shell",
+		exts:        []string{".sh", ".bash", ".zsh"},
+		names:       []string{".bashrc", ".profile", ".zshrc", ".bash_profile"},
+		lineComment: "#",
+		afterSpace:  true,
+		quotes:      "\"'`",
+		symbols:     "|&;<>()[]{}$=",
+		keywords: keywords(`if then else elif fi for while until do done case
+			esac in function return local export select break continue`),
+	},
+}
+
+// languageFor picks the language for a file by its name, nil if there is none
+func languageFor(fname string) *language {
+	base := filepath.Base(fname)
+	ext := strings.ToLower(filepath.Ext(fname))
+	for _, l := range languages {
+		for _, n := range l.names {
+			if base == n {
+				return l
+			}
+		}
+		for _, x := range l.exts {
+			if ext == x {
+				return l
+			}
+		}
+	}
+	return nil
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func hasPrefixAt(rs []rune, i int, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if i >= len(rs) || rs[i] != r {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// indexAt returns the index of s in rs at or after i, -1 if it is not there
+func indexAt(rs []rune, i int, s string) int {
+	for ; i < len(rs); i++ {
+		if hasPrefixAt(rs, i, s) {
+			return i
+		}
+	}
+	return -1
+}
+
+// lex gives the token class of each rune of the line rs, starting in
+// state st, and the state at the end of the line
+func (l *language) lex(rs []rune, st int) ([]int, int) {
+	ids := make([]int, len(rs))
+	fill := func(from, to, id int) {
+		for ; from < to; from++ {
+			ids[from] = id
+		}
+	}
+	i := 0
+	if st > 0 {
+		b := l.blocks[st-1]
+		j := indexAt(rs, 0, b.close)
+		if j == -1 {
+			fill(0, len(rs), b.id)
+			return ids, st
+		}
+		i = j + len([]rune(b.close))
+		fill(0, i, b.id)
+	} else if l.headings && len(rs) > 0 && rs[0] == '#' {
+		fill(0, len(rs), idKeyword)
+		return ids, 0
+	}
+outer:
+	for i < len(rs) {
+		r := rs[i]
+		if hasPrefixAt(rs, i, l.lineComment) &&
+			(!l.afterSpace || i == 0 || unicode.IsSpace(rs[i-1])) {
+			fill(i, len(rs), idLineComment)
+			break
+		}
+		for bi, b := range l.blocks {
+			if !hasPrefixAt(rs, i, b.open) {
+				continue
+			}
+			j := indexAt(rs, i+len([]rune(b.open)), b.close)
+			if j == -1 {
+				fill(i, len(rs), b.id)
+				return ids, bi + 1
+			}
+			end := j + len([]rune(b.close))
+			fill(i, end, b.id)
+			i = end
+			continue outer
+		}
+		start := i
+		switch {
+		case strings.ContainsRune(l.quotes, r):
+			id := idSingleString
+			if r == '"' {
+				id = idDoubleString
+			}
+			for i++; i < len(rs) && rs[i] != r && rs[i] != '\n'; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) && rs[i+1] != '\n' {
+					i++
+				}
+			}
+			if i < len(rs) && rs[i] == r {
+				i++
+			}
+			fill(start, i, id)
+		case unicode.IsDigit(r):
+			for i < len(rs) && (isIdentRune(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			fill(start, i, idDigits)
+		case isIdentRune(r):
+			for i < len(rs) && isIdentRune(rs[i]) {
+				i++
+			}
+			if l.keywords[string(rs[start:i])] {
+				fill(start, i, idKeyword)
+			} else {
+				fill(start, i, idDefault)
+			}
+		case strings.ContainsRune(l.symbols, r):
+			ids[i] = idSymbol
+			i++
+		default:
+			ids[i] = idDefault
+			i++
+		}
+	}
+	return ids, 0
+}
@@ -0,0 +1,152 @@
+package kg
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/kristofer/ke/term"
+	"github.com/stretchr/testify/assert"
+)
+
+// classes renders the token classes of s as one digit per rune
+func classes(l *language, s string, st int) (string, int) {
+	ids, next := l.lex([]rune(s), st)
+	var sb strings.Builder
+	for _, id := range ids {
+		sb.WriteByte(byte('0' + id))
+	}
+	return sb.String(), next
+}
+
+func TestLanguageFor(t *testing.T) {
+	assert.Equal(t, "Go", languageFor("kg/editor.go").name)
+	assert.Equal(t, "Markdown", languageFor("README.MD").name)
+	assert.Equal(t, "Shell", languageFor("/home/k/.bashrc").name)
+	assert.Equal(t, "Shell", languageFor("build.sh").name)
+	assert.Nil(t, languageFor("notes.txt"))
+	assert.Nil(t, languageFor(""))
+}
+
+func TestLexGo(t *testing.T) {
+	golang := languageFor("x.go")
+	for _, c := range []struct {
+		line, want string
+		st, next   int
+	}{
+		{"if x1 := 42; x1 { // hi\n", "991111221442111121555555", 0, 0},
+		{"s := \"a\\\"b\" + 'c'\n", "112217777771218881", 0, 0},
+		{"a /* b */ c /* d\n", "11666666611166666", 0, 1},
+		{"d */ e\n", "6666111", 1, 0},
+		{"x := `raw\n", "1122177777", 0, 2},
+		{"still raw\n", "7777777777", 2, 2},
+		{"end`.f()\n", "777721221", 2, 0},
+	} {
+		got, next := classes(golang, c.line, c.st)
+		assert.Equal(t, c.want, got, c.line)
+		assert.Equal(t, c.next, next, c.line)
+	}
+}
+
+func TestLexMarkdownAndShell(t *testing.T) {
+	md := languageFor("a.md")
+	got, _ := classes(md, "# Title `x`", 0)
+	assert.Equal(t, "99999999999", got)
+	got, _ = classes(md, "see `code` *now*", 0)
+	assert.Equal(t, "1111888888121112", got)
+	_, st := classes(md, "```go", 0)
+	assert.Equal(t, 1, st)
+	got, st = classes(md, "# not a heading", st)
+	assert.Equal(t, "666666666666666", got)
+	assert.Equal(t, 1, st)
+	_, st = classes(md, "```", st)
+	assert.Equal(t, 0, st)
+
+	sh := languageFor("a.sh")
+	got, _ = classes(sh, `if [ $# -gt 0 ]; then echo "$1" # go`, 0)
+	assert.Equal(t, "991212111111412219999111111777715555", got)
+}
+
+// freshIDs are the classes a new highlighter gives the whole buffer
+func freshIDs(bp *Buffer) []int {
+	nb := NewBuffer()
+	nb.Filename = bp.Filename
+	nb.setText(bp.getText())
+	return nb.syntaxIDs(0, nb.TextSize)
+}
+
+func TestHighlightIncremental(t *testing.T) {
+	bp := NewBuffer()
+	bp.Filename = "x.go"
+	var sb strings.Builder
+	for i := 0; i < 200; i++ {
+		sb.WriteString("x := 1 // c\n")
+	}
+	bp.setText(sb.String())
+	bp.syntaxIDs(0, bp.TextSize)
+	assert.Equal(t, 200, len(bp.hl.states))
+
+	// opening a comment changes every line after it
+	bp.SetPoint(bp.text.LineStart(10))
+	bp.Insert("/*")
+	ids := bp.syntaxIDs(bp.text.LineStart(150), bp.text.LineStart(151))
+	assert.Equal(t, idBlockComment, ids[0])
+	assert.Equal(t, freshIDs(bp), bp.syntaxIDs(0, bp.TextSize))
+
+	// and closing it again brings them back
+	bp.Undo()
+	assert.Equal(t, freshIDs(bp), bp.syntaxIDs(0, bp.TextSize))
+
+	// a small edit only relexes up to where the states agree again
+	bp.SetPoint(bp.text.LineStart(100) + 5)
+	bp.Insert("2\n3")
+	bp.syntaxIDs(bp.text.LineStart(100), bp.text.LineStart(103))
+	assert.Equal(t, len(bp.hl.states), bp.hl.valid)
+	assert.Equal(t, 201, len(bp.hl.states))
+	assert.Equal(t, freshIDs(bp), bp.syntaxIDs(0, bp.TextSize))
+}
+
+func TestHighlightModel(t *testing.T) {
+	pieces := []string{"/*", "*/", "`", "\n", "x", " // ", "\"", "if ", "42"}
+	rnd := rand.New(rand.NewSource(7))
+	for _, kind := range storageKinds {
+		bp := NewBufferStorage(kind)
+		bp.Filename = "m.go"
+		bp.setText("a\nb\nc\n")
+		for i := 0; i < 500; i++ {
+			bp.SetPoint(rnd.Intn(bp.TextSize + 1))
+			if rnd.Intn(3) == 0 {
+				bp.Remove(bp.Point, rnd.Intn(4))
+			} else {
+				bp.Insert(pieces[rnd.Intn(len(pieces))])
+			}
+			if rnd.Intn(4) == 0 {
+				from := rnd.Intn(bp.TextSize + 1)
+				bp.syntaxIDs(from, from+20)
+			}
+		}
+		assert.Equal(t, freshIDs(bp), bp.syntaxIDs(0, bp.TextSize), kind.String())
+	}
+}
+
+func TestNoLanguage(t *testing.T) {
+	bp := NewBuffer()
+	bp.Filename = "a.txt"
+	bp.setText("if x\n")
+	assert.Nil(t, bp.syntaxIDs(0, bp.TextSize))
+	bp.Insert("y")
+	assert.Nil(t, bp.syntaxIDs(0, bp.TextSize))
+}
+
+func TestDisplayColours(t *testing.T) {
+	e := withTerm(newTestEditor("if x // c\n"))
+	e.CurrentBuffer.Filename = "a.go"
+	e.BGColor = term.ColorWhite
+	e.hiStart, e.hiEnd = 3, 4
+	e.Display(e.CurrentWindow, true)
+	scr := e.Term.ScrBuf
+	assert.Equal(t, syntaxColors[idKeyword], scr.GetCell(0, 0).Fg)
+	assert.Equal(t, term.AttrReverse, scr.GetCell(3, 0).Fg)
+	assert.Equal(t, syntaxColors[idLineComment], scr.GetCell(6, 0).Fg)
+	assert.Equal(t, term.ColorWhite, scr.GetCell(0, e.CurrentWindow.Rows+1).Bg)
+}
@@ -0,0 +1,47 @@
+package kg
+
+import "github.com/kristofer/ke/buffer"
+
+// tableStorage keeps a Buffer's text in a buffer.Table piece table.
+// kg does its own undo, so the Table history is not used.
+type tableStorage struct {
+	t *buffer.Table
+}
+
+func newTableStorage(s string) *tableStorage {
+	return &tableStorage{t: buffer.NewTable(s)}
+}
+
+func (ts *tableStorage) Len() int {
+	return ts.t.Size()
+}
+
+func (ts *tableStorage) RuneAt(pt int) rune {
+	return ts.t.IndexOf(pt)
+}
+
+func (ts *tableStorage) Slice(from, to int) []rune {
+	return []rune(ts.t.Contents(from, to))
+}
+
+func (ts *tableStorage) Insert(pt int, rs []rune) {
+	_ = ts.t.Insert(string(rs), pt)
+}
+
+func (ts *tableStorage) Delete(pt, n int) []rune {
+	rs := ts.Slice(pt, pt+n)
+	_ = ts.t.Delete(pt, len(rs))
+	return rs
+}
+
+func (ts *tableStorage) Lines() int {
+	return ts.t.Lines()
+}
+
+func (ts *tableStorage) LineStart(n int) int {
+	return ts.t.LineStart(n)
+}
+
+func (ts *tableStorage) LineFor(pt int) int {
+	return ts.t.LineFor(pt)
+}
@@ -0,0 +1,216 @@
+package kg
+
+/*
+ * Undo keeps a history of every change made to a Buffer.
+ * Each record knows where the change happened, the runes that went in or
+ * came out, and where point and mark were before the change, so that
+ * undoing a record puts the cursor back where the user left it.
+ * The records are grouped into steps, one for each command: the first
+ * record of a step is marked, and Undo and Redo take a step at a time.
+ */
+
+type undoKind int
+
+const (
+	undoInsert undoKind = iota
+	undoDelete
+	undoReplace
+	undoLimit = 1000 // max records kept per buffer
+)
+
+type undoRecord struct {
+	kind  undoKind
+	pos   int    // buffer point where the change starts
+	text  []rune // runes inserted or deleted
+	repl  []rune // for undoReplace, the runes that took the place of text
+	point int    // point before the change
+	mark  int    // mark before the change
+	first bool   // the change starts an undo step
+}
+
+type undoList struct {
+	done      []*undoRecord
+	undone    []*undoRecord
+	sealed    bool // next record starts a new undo step
+	suspended bool // true while Undo/Redo replay changes
+}
+
+// boundary closes the current undo step, so the next change will not
+// be merged into it.
+func (u *undoList) boundary() {
+	u.sealed = true
+}
+
+// reset throws away all history, used when the buffer text is replaced.
+func (u *undoList) reset() {
+	u.done = nil
+	u.undone = nil
+	u.sealed = false
+}
+
+func (u *undoList) last() *undoRecord {
+	if len(u.done) == 0 {
+		return nil
+	}
+	return u.done[len(u.done)-1]
+}
+
+func (u *undoList) push(r *undoRecord) {
+	r.first = u.sealed || len(u.done) == 0
+	u.done = append(u.done, r)
+	if len(u.done) > undoLimit {
+		u.done = u.done[len(u.done)-undoLimit:]
+	}
+	u.undone = nil
+	u.sealed = false
+}
+
+// recordInsert notes that text was inserted at pos. Typed runes (merge true)
+// are grouped with the previous insert when they follow on from it.
+func (bp *Buffer) recordInsert(pos int, text []rune, merge bool) {
+	u := &bp.undo
+	if u.suspended || len(text) == 0 {
+		return
+	}
+	if l := u.last(); merge && !u.sealed && l != nil &&
+		l.kind == undoInsert && l.pos+len(l.text) == pos {
+		l.text = append(l.text, text...)
+		u.undone = nil
+		return
+	}
+	r := &undoRecord{kind: undoInsert, pos: pos, point: bp.Point, mark: bp.Mark}
+	r.text = append(r.text, text...)
+	u.push(r)
+}
+
+// recordDelete notes that text was removed starting at pos,
+// with point where it was before the command moved it.
+func (bp *Buffer) recordDelete(pos int, text []rune, point int) {
+	u := &bp.undo
+	if u.suspended || len(text) == 0 {
+		return
+	}
+	r := &undoRecord{kind: undoDelete, pos: pos, point: point, mark: bp.Mark}
+	r.text = append(r.text, text...)
+	u.push(r)
+}
+
+// recordReplace notes that text at pos was swapped for repl,
+// with point where it was before the command moved it.
+func (bp *Buffer) recordReplace(pos int, text, repl []rune, point int) {
+	u := &bp.undo
+	if u.suspended {
+		return
+	}
+	r := &undoRecord{kind: undoReplace, pos: pos, point: point, mark: bp.Mark}
+	r.text = append(r.text, text...)
+	r.repl = append(r.repl, repl...)
+	u.push(r)
+}
+
+// UndoBoundary ends the current undo step
+func (bp *Buffer) UndoBoundary() {
+	bp.undo.boundary()
+}
+
+// CanUndo true if there is a change to undo
+func (bp *Buffer) CanUndo() bool {
+	return len(bp.undo.done) > 0
+}
+
+// CanRedo true if there is an undone change to redo
+func (bp *Buffer) CanRedo() bool {
+	return len(bp.undo.undone) > 0
+}
+
+// Undo reverts the last undo step, all the changes the command made,
+// restoring point and mark.
+// returns false if there was nothing to undo.
+func (bp *Buffer) Undo() bool {
+	r := bp.undoOne()
+	if r == nil {
+		return false
+	}
+	for !r.first {
+		if r = bp.undoOne(); r == nil {
+			break
+		}
+	}
+	bp.undo.sealed = true
+	return true
+}
+
+// undoChange reverts just the last change, for a command that takes back
+// one of its own changes. The next change goes in the same step.
+func (bp *Buffer) undoChange() bool {
+	r := bp.undoOne()
+	if r == nil {
+		return false
+	}
+	bp.undo.sealed = r.first
+	return true
+}
+
+// undoOne reverts the last change record, giving it, nil if there is none
+func (bp *Buffer) undoOne() *undoRecord {
+	u := &bp.undo
+	r := u.last()
+	if r == nil {
+		return nil
+	}
+	u.done = u.done[:len(u.done)-1]
+	u.suspended = true
+	bp.SetPoint(r.pos)
+	switch r.kind {
+	case undoInsert:
+		bp.deleteRunes(len(r.text))
+	case undoDelete:
+		bp.insertRunes(r.text)
+	case undoReplace:
+		bp.deleteRunes(len(r.repl))
+		bp.insertRunes(r.text)
+	}
+	u.suspended = false
+	bp.SetPoint(r.point)
+	bp.Mark = r.mark
+	u.undone = append(u.undone, r)
+	return r
+}
+
+// Redo re-applies the last undone step.
+// returns false if there was nothing to redo.
+func (bp *Buffer) Redo() bool {
+	u := &bp.undo
+	if !bp.redoOne() {
+		return false
+	}
+	for len(u.undone) > 0 && !u.undone[len(u.undone)-1].first {
+		bp.redoOne()
+	}
+	u.sealed = true
+	return true
+}
+
+// redoOne re-applies the last undone change record
+func (bp *Buffer) redoOne() bool {
+	u := &bp.undo
+	if len(u.undone) == 0 {
+		return false
+	}
+	r := u.undone[len(u.undone)-1]
+	u.undone = u.undone[:len(u.undone)-1]
+	u.suspended = true
+	bp.SetPoint(r.pos)
+	switch r.kind {
+	case undoInsert:
+		bp.insertRunes(r.text)
+	case undoDelete:
+		bp.deleteRunes(len(r.text))
+	case undoReplace:
+		bp.deleteRunes(len(r.text))
+		bp.insertRunes(r.repl)
+	}
+	u.suspended = false
+	u.done = append(u.done, r)
+	return true
+}
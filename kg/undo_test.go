@@ -0,0 +1,125 @@
+package kg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndoTyping(t *testing.T) {
+	gb := NewBuffer()
+	s := "Lorem\nlite\n"
+	gb.setText(s)
+	gb.AddRune('f')
+	gb.AddRune('o')
+	gb.AddRune('o')
+	assert.Equal(t, "fooLorem\nlite\n", gb.getText())
+	// consecutive typing is one undo step
+	assert.True(t, gb.Undo())
+	assert.Equal(t, s, gb.getText())
+	assert.Equal(t, 0, gb.Point)
+	assert.False(t, gb.Undo())
+}
+
+func TestUndoBoundary(t *testing.T) {
+	gb := NewBuffer()
+	gb.setText("Lorem\n")
+	gb.AddRune('a')
+	gb.UndoBoundary()
+	gb.AddRune('b')
+	assert.True(t, gb.Undo())
+	assert.Equal(t, "aLorem\n", gb.getText())
+	assert.True(t, gb.Undo())
+	assert.Equal(t, "Lorem\n", gb.getText())
+}
+
+func TestUndoCommand(t *testing.T) {
+	gb := NewBuffer()
+	s := "Lorem\nlite\n"
+	gb.setText(s)
+	gb.SetPoint(2)
+	gb.AddRune('x')
+	// one command making several changes is one undo step
+	gb.UndoBoundary()
+	gb.Remove(0, 1)
+	gb.Insert("ab")
+	gb.Replace(8, 4, "LITE")
+	assert.Equal(t, "aboxrem\nLITE\n", gb.getText())
+	assert.True(t, gb.Undo())
+	assert.Equal(t, "Loxrem\nlite\n", gb.getText())
+	assert.Equal(t, 3, gb.Point)
+	assert.True(t, gb.Redo())
+	assert.Equal(t, "aboxrem\nLITE\n", gb.getText())
+	assert.False(t, gb.Redo())
+	assert.True(t, gb.Undo())
+	assert.True(t, gb.Undo())
+	assert.Equal(t, s, gb.getText())
+	assert.False(t, gb.Undo())
+}
+
+func TestUndoInsertString(t *testing.T) {
+	gb := NewBuffer()
+	gb.setText("Lorem\n")
+	gb.SetPoint(2)
+	gb.Insert("Οὐχὶ")
+	assert.Equal(t, "LoΟὐχὶrem\n", gb.getText())
+	assert.Equal(t, 6, gb.Point)
+	assert.True(t, gb.Undo())
+	assert.Equal(t, "Lorem\n", gb.getText())
+	assert.Equal(t, 2, gb.Point)
+}
+
+func TestUndoDeleteBackspace(t *testing.T) {
+	gb := NewBuffer()
+	s := "Lorem\nlite\n"
+	gb.setText(s)
+	gb.SetPoint(3)
+	gb.Delete()
+	gb.UndoBoundary()
+	gb.Backspace()
+	assert.Equal(t, "Lom\nlite\n", gb.getText())
+	assert.True(t, gb.Undo())
+	assert.Equal(t, "Lorm\nlite\n", gb.getText())
+	assert.Equal(t, 3, gb.Point)
+	assert.True(t, gb.Undo())
+	assert.Equal(t, s, gb.getText())
+	assert.Equal(t, 3, gb.Point)
+}
+
+func TestUndoRemoveRestoresMark(t *testing.T) {
+	gb := NewBuffer()
+	s := "Lorem\nlite\nsed ut\n"
+	gb.setText(s)
+	gb.SetPoint(8)
+	gb.Mark = 2
+	gb.Remove(2, 6)
+	gb.Mark = nomark
+	assert.Equal(t, "Lote\nsed ut\n", gb.getText())
+	assert.True(t, gb.Undo())
+	assert.Equal(t, s, gb.getText())
+	assert.Equal(t, 8, gb.Point)
+	assert.Equal(t, 2, gb.Mark)
+}
+
+func TestRedo(t *testing.T) {
+	gb := NewBuffer()
+	s := "Lorem\nlite\n"
+	gb.setText(s)
+	gb.SetPoint(6)
+	gb.Insert("foo ")
+	gb.UndoBoundary()
+	gb.Remove(0, 2)
+	assert.Equal(t, "rem\nfoo lite\n", gb.getText())
+	assert.True(t, gb.Undo())
+	assert.True(t, gb.Undo())
+	assert.Equal(t, s, gb.getText())
+	assert.True(t, gb.Redo())
+	assert.Equal(t, "Lorem\nfoo lite\n", gb.getText())
+	assert.True(t, gb.Redo())
+	assert.Equal(t, "rem\nfoo lite\n", gb.getText())
+	assert.False(t, gb.Redo())
+	// a new change drops the redo list
+	assert.True(t, gb.Undo())
+	gb.AddRune('x')
+	assert.False(t, gb.CanRedo())
+}
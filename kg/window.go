@@ -50,15 +50,63 @@ func (wp *Window) OneWindow() {
 	wp.Next = nil
 }
 
-// WindowResize xxx
-func (wp *Window) WindowResize() {
-	wp.Editor.CurrentWindow.OneWindow()
+// minWindowLines is the least a window takes on the screen, a line of
+// text, the line below it and the modeline
+const minWindowLines = 3
+
+// layoutWindows fits the windows, top to bottom, into the lines the screen
+// has now, each as big a share of it as it had before. Windows at the
+// bottom that no longer fit at all are closed, never the current one.
+func (e *Editor) layoutWindows() {
+	avail := e.Lines - 1 // less the message line
+	var wins []*Window
+	for wp := e.RootWindow; wp != nil; wp = wp.Next {
+		wins = append(wins, wp)
+	}
+	for len(wins) > 1 && len(wins)*minWindowLines > avail {
+		i := len(wins) - 1
+		if wins[i] == e.CurrentWindow {
+			i--
+		}
+		wins[i].DisassociateBuffer()
+		wins = append(wins[:i], wins[i+1:]...)
+	}
+	total := 0 // lines the windows took before
+	for _, wp := range wins {
+		total += wp.Rows + 2
+	}
+	top, sofar := 0, 0
+	for i, wp := range wins {
+		sofar += wp.Rows + 2
+		end := avail
+		if i < len(wins)-1 {
+			end = (avail*sofar + total/2) / total
+			if end < top+minWindowLines {
+				end = top + minWindowLines
+			}
+			if rest := avail - (len(wins)-1-i)*minWindowLines; end > rest {
+				end = rest
+			}
+			wp.Next = wins[i+1]
+		} else {
+			wp.Next = nil
+		}
+		wp.TopPt = top
+		wp.Rows = end - top - 2
+		wp.Updated = true
+		top = end
+	}
+	e.RootWindow = wins[0]
+	e.CurrentBuffer.Reframe = true
 }
 
 // OnKey handles the buffer insertion of non-control/editor keys
 //
 
 func (wp *Window) OnKey(ev *term.Event) {
+	if wp.Editor != nil {
+		wp.Editor.lastCmd = cmdOther
+	}
 	switch ev.Key {
 	case term.KeySpace:
 		wp.Buffer.AddRune(' ')
@@ -103,7 +151,6 @@ func window2Buffer(w *Window) {
 	/* fixup Pointers in other windows of the same buffer, if size of edit text changed */
 	if b.Point > b.OrigPoint {
 		sizeDelta := b.TextSize - b.PrevSize
-		b.MoveGap(sizeDelta)
 		b.SetPoint(b.Point + sizeDelta)
 		b.PageStart += sizeDelta
 		b.PageEnd += sizeDelta
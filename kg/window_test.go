@@ -0,0 +1,65 @@
+package kg
+
+import (
+	"testing"
+
+	"github.com/kristofer/ke/term"
+	"github.com/stretchr/testify/assert"
+)
+
+// windowRows gives the top and rows of each window, top to bottom
+func windowRows(e *Editor) [][2]int {
+	var rs [][2]int
+	for wp := e.RootWindow; wp != nil; wp = wp.Next {
+		rs = append(rs, [2]int{wp.TopPt, wp.Rows})
+	}
+	return rs
+}
+
+func TestResizeOneWindow(t *testing.T) {
+	e := withTerm(newTestEditor("one\ntwo\n"))
+	e.HandleEvent(&term.Event{Type: term.EventResize, Width: 132, Height: 50})
+	assert.Equal(t, 132, e.Cols)
+	assert.Equal(t, 50, e.Lines)
+	c, r := e.Term.Size()
+	assert.Equal(t, 132, c)
+	assert.Equal(t, 50, r)
+	assert.Equal(t, [][2]int{{0, 47}}, windowRows(e))
+}
+
+func TestResizeKeepsSplits(t *testing.T) {
+	e := withTerm(newTestEditor("one\ntwo\n"))
+	e.splitWindow()
+	assert.Equal(t, [][2]int{{0, 10}, {12, 9}}, windowRows(e))
+
+	e.Resize(80, 48)
+	assert.Equal(t, [][2]int{{0, 23}, {25, 20}}, windowRows(e))
+	e.Resize(80, 24)
+	assert.Equal(t, [][2]int{{0, 10}, {12, 9}}, windowRows(e))
+
+	// the bottom window gets the rounding, and none gets less than a line
+	e.splitWindow()
+	e.Resize(80, 10)
+	assert.Equal(t, [][2]int{{0, 1}, {3, 1}, {6, 1}}, windowRows(e))
+}
+
+func TestResizeClosesWindows(t *testing.T) {
+	e := withTerm(newTestEditor("one\ntwo\n"))
+	e.splitWindow()
+	e.splitWindow()
+	e.nextWindow()
+	e.nextWindow()
+	cur := e.CurrentWindow
+	assert.Equal(t, cur, e.RootWindow.Next.Next)
+
+	// too small for three windows, the current one is kept
+	e.Resize(80, 7)
+	assert.Equal(t, [][2]int{{0, 1}, {3, 1}}, windowRows(e))
+	assert.Equal(t, cur, e.RootWindow.Next)
+	assert.Equal(t, 2, e.CurrentBuffer.WinCount)
+
+	e.Resize(80, 2)
+	assert.Equal(t, 4, e.Lines)
+	assert.Equal(t, [][2]int{{0, 1}}, windowRows(e))
+	assert.Equal(t, cur, e.RootWindow)
+}
@@ -0,0 +1,84 @@
+package kg
+
+import "unicode"
+
+/*
+ * Words are runs of Unicode letters and digits; everything else
+ * (spaces, punctuation, newlines) separates them.
+ */
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// wordForward returns the point just past the end of the word at or after pt
+func (bp *Buffer) wordForward(pt int) int {
+	if pt < 0 {
+		pt = 0
+	}
+	for pt < bp.TextSize && !isWordRune(bp.text.RuneAt(pt)) {
+		pt++
+	}
+	for pt < bp.TextSize && isWordRune(bp.text.RuneAt(pt)) {
+		pt++
+	}
+	return pt
+}
+
+// wordBackward returns the point of the start of the word before pt
+func (bp *Buffer) wordBackward(pt int) int {
+	if pt > bp.TextSize {
+		pt = bp.TextSize
+	}
+	for pt > 0 && !isWordRune(bp.text.RuneAt(pt-1)) {
+		pt--
+	}
+	for pt > 0 && isWordRune(bp.text.RuneAt(pt-1)) {
+		pt--
+	}
+	return pt
+}
+
+// caseWord applies f to the runes of the word after point (the first
+// rune of the word is mapped by first), and leaves point after the word.
+func (bp *Buffer) caseWord(first, f func(rune) rune) {
+	end := bp.wordForward(bp.Point)
+	rs := bp.text.Slice(bp.Point, end)
+	if nrs := mapRunes(string(rs), first, f); string(nrs) != string(rs) {
+		bp.Replace(bp.Point, end-bp.Point, string(nrs))
+	}
+	bp.SetPoint(end)
+}
+
+func (e *Editor) wleft() {
+	bp := e.CurrentBuffer
+	bp.SetPoint(bp.wordBackward(bp.Point))
+}
+
+func (e *Editor) wright() {
+	bp := e.CurrentBuffer
+	bp.SetPoint(bp.wordForward(bp.Point))
+}
+
+func (e *Editor) killWord() {
+	bp := e.CurrentBuffer
+	e.kill(bp.Point, bp.wordForward(bp.Point)-bp.Point)
+}
+
+func (e *Editor) backwardKillWord() {
+	bp := e.CurrentBuffer
+	start := bp.wordBackward(bp.Point)
+	e.kill(start, bp.Point-start)
+}
+
+func (e *Editor) upcaseWord() {
+	e.CurrentBuffer.caseWord(unicode.ToUpper, unicode.ToUpper)
+}
+
+func (e *Editor) downcaseWord() {
+	e.CurrentBuffer.caseWord(unicode.ToLower, unicode.ToLower)
+}
+
+func (e *Editor) capitalizeWord() {
+	e.CurrentBuffer.caseWord(unicode.ToTitle, unicode.ToLower)
+}
@@ -0,0 +1,85 @@
+package kg
+
+import (
+	"testing"
+
+	"github.com/kristofer/ke/term"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestEditor is an Editor with no terminal, editing s
+func newTestEditor(s string) *Editor {
+	e := &Editor{Keymap: Keymap}
+	e.CurrentBuffer = NewBuffer()
+	e.CurrentBuffer.setText(s)
+	return e
+}
+
+// escKey runs the esc-prefixed command bound to ch
+func escKey(e *Editor, ch rune) bool {
+	e.EscapeFlag = true
+	return e.RunKeymapFunction(&term.Event{Type: term.EventKey, Ch: ch})
+}
+
+func TestWordMotion(t *testing.T) {
+	e := newTestEditor("foo, bar_baz  Οὐχὶ42 ταὐτὰ\n")
+	bp := e.CurrentBuffer
+	for _, want := range []int{3, 8, 12, 20, 26, 27, 27} {
+		e.wright()
+		assert.Equal(t, want, bp.Point)
+	}
+	for _, want := range []int{21, 14, 9, 5, 0, 0} {
+		e.wleft()
+		assert.Equal(t, want, bp.Point)
+	}
+}
+
+func TestKillWord(t *testing.T) {
+	e := newTestEditor("foo, bar baz\n")
+	bp := e.CurrentBuffer
+	bp.SetPoint(3)
+	assert.True(t, escKey(e, 'd'))
+	assert.Equal(t, "foo baz\n", bp.getText())
+	assert.Equal(t, 3, bp.Point)
+	bp.Undo()
+	assert.Equal(t, "foo, bar baz\n", bp.getText())
+}
+
+func TestBackwardKillWord(t *testing.T) {
+	e := newTestEditor("foo, bar baz\n")
+	bp := e.CurrentBuffer
+	bp.SetPoint(10)
+	assert.True(t, escKey(e, 0x7f))
+	assert.Equal(t, "foo, bar az\n", bp.getText())
+	assert.Equal(t, 9, bp.Point)
+	assert.True(t, escKey(e, 0x7f))
+	assert.Equal(t, "foo, az\n", bp.getText())
+	assert.True(t, escKey(e, 0x7f))
+	assert.Equal(t, "az\n", bp.getText())
+	assert.Equal(t, 0, bp.Point)
+}
+
+func TestCaseWords(t *testing.T) {
+	e := newTestEditor("hello wORLD  ὐχὶ done\n")
+	bp := e.CurrentBuffer
+	assert.True(t, escKey(e, 'u'))
+	assert.Equal(t, "HELLO wORLD  ὐχὶ done\n", bp.getText())
+	assert.Equal(t, 5, bp.Point)
+	assert.True(t, escKey(e, 'c'))
+	assert.Equal(t, "HELLO World  ὐχὶ done\n", bp.getText())
+	assert.Equal(t, 11, bp.Point)
+	assert.True(t, escKey(e, 'u'))
+	assert.Equal(t, "HELLO World  ὐΧῚ done\n", bp.getText())
+	bp.SetPoint(0)
+	assert.True(t, escKey(e, 'l'))
+	assert.Equal(t, "hello World  ὐΧῚ done\n", bp.getText())
+
+	// each case change is a single undo step
+	bp.Undo()
+	assert.Equal(t, "HELLO World  ὐΧῚ done\n", bp.getText())
+	assert.Equal(t, 0, bp.Point)
+	bp.Undo()
+	assert.Equal(t, "HELLO World  ὐχὶ done\n", bp.getText())
+	bp.Redo()
+	assert.Equal(t, "HELLO World  ὐΧῚ done\n", bp.getText())
+}
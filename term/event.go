@@ -19,11 +19,14 @@ const (
 	EventInterrupt
 	EventRaw
 	EventNone
+	EventPaste
 )
 
 // This type represents a term event. The 'Mod', 'Key' and 'Ch' fields are
 // valid if 'Type' is EventKey. The 'Width' and 'Height' fields are valid if
 // 'Type' is EventResize. The 'Err' field is valid if 'Type' is EventError.
+// The 'Text' field is valid if 'Type' is EventPaste. For EventMouse 'Key'
+// is one of the Mouse* constants, at 'MouseX' and 'MouseY'.
 type Event struct {
 	Type   EventType // one of Event* constants
 	Mod    Modifier  // one of Mod* constants or 0
@@ -35,6 +38,7 @@ type Event struct {
 	MouseX int       // x coord of mouse
 	MouseY int       // y coord of mouse
 	N      int       // number of bytes written when getting a raw event
+	Text   string    // text pasted
 }
 
 func (ev *Event) String() string {
@@ -186,6 +190,8 @@ const (
 const (
 	ModAlt Modifier = 1 << iota
 	ModMotion
+	ModShift
+	ModCtrl
 )
 
 // Input mode. See SetInputMode function.
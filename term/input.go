@@ -0,0 +1,282 @@
+package term
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+ * Keyboard input from a terminal is bytes: plain text, control characters,
+ * and escape sequences for the keys that have no character. A sequence is
+ * ESC [ params final (CSI), or ESC O final (SS3); xterm adds the modifiers
+ * held down as a second parameter, 1 + shift(1) + alt(2) + ctrl(4) +
+ * meta(8), so Ctrl+Left is ESC [ 1 ; 5 D. ESC in front of any other key is
+ * that key with Alt. A lone ESC is told apart from the start of a sequence
+ * by waiting EscTimeout for the rest of it.
+ */
+
+// EscTimeout is how long to wait after an ESC for the rest of a sequence
+var EscTimeout = 50 * time.Millisecond
+
+const esc = 0x1b
+
+// keys for CSI and SS3 final bytes
+var finalKeys = map[byte]Key{
+	'A': KeyArrowUp,
+	'B': KeyArrowDown,
+	'C': KeyArrowRight,
+	'D': KeyArrowLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+// keys for CSI n ~
+var tildeKeys = map[int]Key{
+	1:  KeyHome,
+	2:  KeyInsert,
+	3:  KeyDelete,
+	4:  KeyEnd,
+	5:  KeyPgup,
+	6:  KeyPgdn,
+	7:  KeyHome,
+	8:  KeyEnd,
+	11: KeyF1,
+	12: KeyF2,
+	13: KeyF3,
+	14: KeyF4,
+	15: KeyF5,
+	17: KeyF6,
+	18: KeyF7,
+	19: KeyF8,
+	20: KeyF9,
+	21: KeyF10,
+	23: KeyF11,
+	24: KeyF12,
+}
+
+// ParseKey decodes the key at the start of b, giving its event and the
+// number of bytes it took. If b stops part way through a sequence it gives
+// 0 bytes when more is true, for the caller to read more and try again;
+// when no more is coming the ESC is taken as a key on its own.
+func ParseKey(b []byte, more bool) (Event, int) {
+	ev := Event{Type: EventKey}
+	if len(b) == 0 {
+		return Event{Type: EventNone}, 0
+	}
+	if b[0] != esc {
+		// control keys, space, and DEL, which is what Backspace sends,
+		// come as keys, as they do from the web frontend
+		if b[0] <= ' ' || b[0] == 0x7f {
+			ev.Key = Key(b[0])
+			return ev, 1
+		}
+		if more && !utf8.FullRune(b) {
+			return ev, 0
+		}
+		r, n := utf8.DecodeRune(b)
+		ev.Ch = r
+		return ev, n
+	}
+	if len(b) == 1 {
+		if more {
+			return ev, 0
+		}
+		ev.Key = KeyEsc
+		return ev, 1
+	}
+	switch b[1] {
+	case '[':
+		if ev, n := parseCSI(b); n != 0 || more {
+			return ev, n
+		}
+	case 'O':
+		if len(b) > 2 {
+			if k, ok := finalKeys[b[2]]; ok {
+				ev.Key = k
+				return ev, 3
+			}
+		} else if more {
+			return ev, 0
+		}
+	}
+	// ESC in front of a key is that key with Alt
+	ev, n := ParseKey(b[1:], more)
+	if n == 0 {
+		if more {
+			return ev, 0
+		}
+		ev.Key = KeyEsc
+		return ev, 1
+	}
+	ev.Mod |= ModAlt
+	return ev, n + 1
+}
+
+// parseCSI decodes the ESC [ sequence at the start of b, giving 0 bytes if
+// it is not all there. A sequence for a key it does not know is an
+// EventNone.
+func parseCSI(b []byte) (Event, int) {
+	ev := Event{Type: EventKey}
+	i := 2
+	for i < len(b) && b[i] >= 0x20 && b[i] <= 0x3f {
+		i++
+	}
+	if i == len(b) {
+		return ev, 0
+	}
+	final := b[i]
+	n := i + 1
+	if final < 0x40 || final > 0x7e {
+		// not a sequence after all
+		return Event{Type: EventNone}, i
+	}
+	params := strings.Split(string(b[2:i]), ";")
+	num := func(k int) int {
+		if k >= len(params) {
+			return 0
+		}
+		v, _ := strconv.Atoi(params[k])
+		return v
+	}
+	switch {
+	case final == '~':
+		k, ok := tildeKeys[num(0)]
+		if !ok {
+			return Event{Type: EventNone}, n
+		}
+		ev.Key = k
+	case final == 'Z':
+		ev.Key = KeyTab
+		ev.Mod = ModShift
+		return ev, n
+	default:
+		k, ok := finalKeys[final]
+		if !ok {
+			return Event{Type: EventNone}, n
+		}
+		ev.Key = k
+	}
+	ev.Mod = xtermMods(num(1))
+	return ev, n
+}
+
+// xtermMods gives the modifiers of an xterm modifier parameter
+func xtermMods(p int) Modifier {
+	var m Modifier
+	if p < 2 {
+		return 0
+	}
+	p--
+	if p&1 != 0 {
+		m |= ModShift
+	}
+	if p&(2|8) != 0 {
+		m |= ModAlt
+	}
+	if p&4 != 0 {
+		m |= ModCtrl
+	}
+	return m
+}
+
+// KeySeq gives the sequence an xterm sends for key with the modifiers in
+// mod, "" if key is not one of the keys without a character
+func KeySeq(key Key, mod Modifier) string {
+	p := 1
+	if mod&ModShift != 0 {
+		p++
+	}
+	if mod&ModAlt != 0 {
+		p += 2
+	}
+	if mod&ModCtrl != 0 {
+		p += 4
+	}
+	for final, k := range finalKeys {
+		if k != key {
+			continue
+		}
+		switch {
+		case p > 1:
+			return fmt.Sprintf("%s1;%d%c", CSI, p, final)
+		case key >= KeyF4 && key <= KeyF1:
+			return fmt.Sprintf("\x1bO%c", final)
+		default:
+			return fmt.Sprintf("%s%c", CSI, final)
+		}
+	}
+	for n := 2; n <= 24; n++ {
+		if k, ok := tildeKeys[n]; !ok || k != key {
+			continue
+		}
+		if p > 1 {
+			return fmt.Sprintf("%s%d;%d~", CSI, n, p)
+		}
+		return fmt.Sprintf("%s%d~", CSI, n)
+	}
+	return ""
+}
+
+// startInput reads the terminal in the background, so PollEvent can stop
+// waiting for the rest of a sequence when EscTimeout runs out
+func (t *Term) startInput() {
+	t.inbytes = make(chan []byte)
+	go func() {
+		for {
+			buf := make([]byte, 256)
+			n, err := t.Input.Read(buf)
+			if n > 0 {
+				t.inbytes <- buf[:n]
+			}
+			if err != nil {
+				t.inerr = err
+				close(t.inbytes)
+				return
+			}
+		}
+	}()
+}
+
+// PollEvent waits for the next key from a Pty terminal, or for it to
+// change size. A read error, such as the end of the input, comes back as an
+// EventError once the keys before it are used up.
+func (t *Term) PollEvent() Event {
+	if t.inbytes == nil {
+		t.startInput()
+	}
+	for {
+		if len(t.pending) > 0 {
+			if ev, n := ParseKey(t.pending, !t.inclosed); n > 0 {
+				t.pending = t.pending[n:]
+				return ev
+			}
+			select {
+			case b, ok := <-t.inbytes:
+				t.pending = append(t.pending, b...)
+				t.inclosed = !ok
+			case <-time.After(EscTimeout):
+				ev, n := ParseKey(t.pending, false)
+				t.pending = t.pending[n:]
+				return ev
+			}
+			continue
+		}
+		select {
+		case b, ok := <-t.inbytes:
+			if !ok {
+				t.inclosed = true
+				return Event{Type: EventError, Err: t.inerr}
+			}
+			t.pending = append(t.pending, b...)
+		case <-t.winch:
+			return t.resizeEvent()
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package term
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		in  string
+		key Key
+		ch  rune
+		mod Modifier
+		n   int
+	}{
+		{"a", 0, 'a', 0, 1},
+		{"é!", 0, 'é', 0, 2},
+		{"\x13", KeyCtrlS, 0, 0, 1},
+		{" ", KeySpace, 0, 0, 1},
+		{"\x7f", KeyBackspace2, 0, 0, 1},
+		{"\x1b\x7f", KeyBackspace2, 0, ModAlt, 2},
+		{"\x1b[A", KeyArrowUp, 0, 0, 3},
+		{"\x1bOD", KeyArrowLeft, 0, 0, 3},
+		{"\x1b[H", KeyHome, 0, 0, 3},
+		{"\x1b[4~", KeyEnd, 0, 0, 4},
+		{"\x1b[5~x", KeyPgup, 0, 0, 4},
+		{"\x1b[6~", KeyPgdn, 0, 0, 4},
+		{"\x1b[2~", KeyInsert, 0, 0, 4},
+		{"\x1b[3~", KeyDelete, 0, 0, 4},
+		{"\x1bOP", KeyF1, 0, 0, 3},
+		{"\x1b[15~", KeyF5, 0, 0, 5},
+		{"\x1b[24~", KeyF12, 0, 0, 5},
+		{"\x1b[1;5D", KeyArrowLeft, 0, ModCtrl, 6},
+		{"\x1b[1;2C", KeyArrowRight, 0, ModShift, 6},
+		{"\x1b[3;7~", KeyDelete, 0, ModCtrl | ModAlt, 6},
+		{"\x1b[Z", KeyTab, 0, ModShift, 3},
+		{"\x1bf", 0, 'f', ModAlt, 2},
+		{"\x1b\x13", KeyCtrlS, 0, ModAlt, 2},
+		{"\x1b\x1b[A", KeyArrowUp, 0, ModAlt, 4},
+	}
+	for _, tt := range tests {
+		ev, n := ParseKey([]byte(tt.in), true)
+		if ev.Type != EventKey || ev.Key != tt.key || ev.Ch != tt.ch || ev.Mod != tt.mod || n != tt.n {
+			t.Errorf("ParseKey(%q) = %s, %d; want Key(%v) Ch(%v) Mod(%v), %d",
+				tt.in, ev.String(), n, tt.key, tt.ch, tt.mod, tt.n)
+		}
+	}
+}
+
+func TestParseKeyPartial(t *testing.T) {
+	for _, in := range []string{"\x1b", "\x1b[", "\x1b[1;5", "\x1bO", "\x1b\x1b", "\xc3"} {
+		if _, n := ParseKey([]byte(in), true); n != 0 {
+			t.Errorf("ParseKey(%q, more) took %d bytes of a partial key", in, n)
+		}
+	}
+	// with no more coming, the ESC is a key of its own
+	if ev, n := ParseKey([]byte("\x1b"), false); ev.Key != KeyEsc || n != 1 {
+		t.Errorf("lone ESC = %s, %d", ev.String(), n)
+	}
+	if ev, n := ParseKey([]byte("\x1b["), false); ev.Ch != '[' || ev.Mod != ModAlt || n != 2 {
+		t.Errorf("ESC [ = %s, %d", ev.String(), n)
+	}
+	// an unknown sequence is used up without a key
+	if ev, n := ParseKey([]byte("\x1b[200~"), true); ev.Type != EventNone || n != 6 {
+		t.Errorf("unknown sequence = %s, %d", ev.String(), n)
+	}
+}
+
+func TestKeySeq(t *testing.T) {
+	keys := []Key{KeyArrowUp, KeyArrowDown, KeyArrowLeft, KeyArrowRight,
+		KeyHome, KeyEnd, KeyPgup, KeyPgdn, KeyInsert, KeyDelete,
+		KeyF1, KeyF2, KeyF3, KeyF4, KeyF5, KeyF6, KeyF7, KeyF8, KeyF9, KeyF10, KeyF11, KeyF12}
+	for _, k := range keys {
+		for _, mod := range []Modifier{0, ModCtrl, ModShift | ModAlt} {
+			seq := KeySeq(k, mod)
+			ev, n := ParseKey([]byte(seq), true)
+			if ev.Key != k || ev.Mod != mod || n != len(seq) {
+				t.Errorf("KeySeq(%v, %v) = %q, which parses as %s", k, mod, seq, ev.String())
+			}
+		}
+	}
+	if seq := KeySeq(KeyArrowUp, 0); seq != "\x1b[A" {
+		t.Errorf("KeySeq(KeyArrowUp) = %q", seq)
+	}
+	if seq := KeySeq(KeyCtrlA, 0); seq != "" {
+		t.Errorf("KeySeq(KeyCtrlA) = %q", seq)
+	}
+}
+
+func TestPollEvent(t *testing.T) {
+	term := &Term{Kind: Pty, Input: bufio.NewReader(strings.NewReader("x\x1b[Dé\x1b"))}
+	want := []Event{
+		{Type: EventKey, Ch: 'x'},
+		{Type: EventKey, Key: KeyArrowLeft},
+		{Type: EventKey, Ch: 'é'},
+		{Type: EventKey, Key: KeyEsc},
+	}
+	for _, w := range want {
+		if ev := term.PollEvent(); ev.Type != w.Type || ev.Key != w.Key || ev.Ch != w.Ch {
+			t.Errorf("PollEvent = %s, want %s", ev.String(), w.String())
+		}
+	}
+	if ev := term.PollEvent(); ev.Type != EventError || ev.Err != io.EOF {
+		t.Errorf("PollEvent at the end = %s %v", ev.String(), ev.Err)
+	}
+}
+
+func TestPollEventEscTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	term := &Term{Kind: Pty, Input: bufio.NewReader(r)}
+	go func() {
+		w.Write([]byte("\x1b"))
+		time.Sleep(EscTimeout * 3)
+		w.Write([]byte("\x1b"))
+		w.Write([]byte("[1;5C"))
+	}()
+	if ev := term.PollEvent(); ev.Key != KeyEsc || ev.Mod != 0 {
+		t.Errorf("ESC then a pause = %s", ev.String())
+	}
+	if ev := term.PollEvent(); ev.Key != KeyArrowRight || ev.Mod != ModCtrl {
+		t.Errorf("a sequence in two reads = %s", ev.String())
+	}
+}
+
+func TestEventFromMessage(t *testing.T) {
+	term := NewTerm(Web)
+	ev := term.EventFromKey([]byte(`{"type":"resize","cols":120,"rows":40}`))
+	if ev.Type != EventResize || ev.Width != 120 || ev.Height != 40 {
+		t.Errorf("resize message = %s %dx%d", ev.String(), ev.Width, ev.Height)
+	}
+	if ev := term.EventFromKey([]byte("{")); ev.Type != EventKey || ev.Ch != '{' {
+		t.Errorf("{ key = %s", ev.String())
+	}
+	for _, bad := range []string{`{"type":"resize","cols":0,"rows":40}`, `{"type":"nope"}`, `{bad`} {
+		if ev := term.EventFromKey([]byte(bad)); ev.Type != EventError {
+			t.Errorf("%s = %s", bad, ev.String())
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package term
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+/*
+ * The web frontend and the editor talk over the websocket in JSON
+ * messages, each an object whose "type" says what it is:
+ *
+ * browser to editor
+ *	hello   version, session   first, with the token of a session to resume
+ *	key     key, ctrl, alt, shift   a key, named as KeyboardEvent.key names it
+ *	paste   text
+ *	mouse   action, button, col, row   action is press, release, wheelup or wheeldown
+ *	resize  cols, rows
+ *
+ * editor to browser
+ *	hello   version, session   the session's token, before anything else
+ *	frame   cols, rows, text, runs, cursor   the whole screen
+ *	diff    cols, rows, spans, cursor   what changed since the last frame or diff
+ *	cursor  cursor   the cursor moved, and nothing else changed
+ *	title   text
+ *	status  text     the message line
+ *	bell
+ *	error   text     before the editor closes the websocket
+ */
+
+// ProtocolVersion is the version of the messages above. It changes when a
+// message changes in a way the other end would get wrong.
+const ProtocolVersion = 1
+
+// Message types
+const (
+	MsgHello  = "hello"
+	MsgKey    = "key"
+	MsgPaste  = "paste"
+	MsgMouse  = "mouse"
+	MsgResize = "resize"
+	MsgFrame  = "frame"
+	MsgDiff   = "diff"
+	MsgCursor = "cursor"
+	MsgTitle  = "title"
+	MsgStatus = "status"
+	MsgBell   = "bell"
+	MsgError  = "error"
+)
+
+// Message is any message of the protocol, with the fields its Type uses.
+// Frame and Damage are the frame and diff messages as the editor sends
+// them.
+type Message struct {
+	Type    string  `json:"type"`
+	Version int     `json:"version,omitempty"`
+	Session string  `json:"session,omitempty"`
+	Key     string  `json:"key,omitempty"`
+	Ctrl    bool    `json:"ctrl,omitempty"`
+	Alt     bool    `json:"alt,omitempty"`
+	Shift   bool    `json:"shift,omitempty"`
+	Text    string  `json:"text,omitempty"`
+	Action  string  `json:"action,omitempty"`
+	Button  int     `json:"button,omitempty"`
+	Col     int     `json:"col,omitempty"`
+	Row     int     `json:"row,omitempty"`
+	Cols    int     `json:"cols,omitempty"`
+	Rows    int     `json:"rows,omitempty"`
+	Runs    []Run   `json:"runs,omitempty"`
+	Spans   []Span  `json:"spans,omitempty"`
+	Cursor  *[2]int `json:"cursor,omitempty"`
+}
+
+// keys by the names KeyboardEvent.key gives them
+var namedKeys = map[string]Key{
+	"ArrowUp":    KeyArrowUp,
+	"ArrowDown":  KeyArrowDown,
+	"ArrowLeft":  KeyArrowLeft,
+	"ArrowRight": KeyArrowRight,
+	"Home":       KeyHome,
+	"End":        KeyEnd,
+	"PageUp":     KeyPgup,
+	"PageDown":   KeyPgdn,
+	"Insert":     KeyInsert,
+	"Delete":     KeyDelete,
+	"F1":         KeyF1,
+	"F2":         KeyF2,
+	"F3":         KeyF3,
+	"F4":         KeyF4,
+	"F5":         KeyF5,
+	"F6":         KeyF6,
+	"F7":         KeyF7,
+	"F8":         KeyF8,
+	"F9":         KeyF9,
+	"F10":        KeyF10,
+	"F11":        KeyF11,
+	"F12":        KeyF12,
+	"Enter":      KeyEnter,
+	"Tab":        KeyTab,
+	"Backspace":  KeyBackspace2,
+	"Escape":     KeyEsc,
+}
+
+// mouse keys for the actions of a mouse message, and for the buttons
+// pressed
+var (
+	mouseActions = map[string]Key{
+		"release":   MouseRelease,
+		"wheelup":   MouseWheelUp,
+		"wheeldown": MouseWheelDown,
+	}
+	mouseButtons = []Key{MouseLeft, MouseMiddle, MouseRight}
+)
+
+// EventFromMessage decodes a message from the web frontend. A hello, or a
+// key the editor has no use for, such as Shift on its own, is an
+// EventNone; a hello of another version of the protocol is an EventError.
+func EventFromMessage(msg []byte) Event {
+	var m Message
+	if err := json.Unmarshal(msg, &m); err != nil {
+		return Event{Type: EventError, Err: err}
+	}
+	switch m.Type {
+	case MsgHello:
+		if m.Version != ProtocolVersion {
+			return Event{Type: EventError, Err: fmt.Errorf("protocol version %d, want %d", m.Version, ProtocolVersion)}
+		}
+		return Event{Type: EventNone}
+	case MsgKey:
+		return keyEvent(&m)
+	case MsgPaste:
+		return Event{Type: EventPaste, Text: m.Text}
+	case MsgMouse:
+		ev := Event{Type: EventMouse, MouseX: m.Col, MouseY: m.Row}
+		if m.Action == "press" && m.Button >= 0 && m.Button < len(mouseButtons) {
+			ev.Key = mouseButtons[m.Button]
+		} else if k, ok := mouseActions[m.Action]; ok {
+			ev.Key = k
+		} else {
+			return Event{Type: EventError, Err: fmt.Errorf("bad mouse %s of button %d", m.Action, m.Button)}
+		}
+		return ev
+	case MsgResize:
+		if m.Cols < 1 || m.Rows < 1 {
+			return Event{Type: EventError, Err: fmt.Errorf("bad size %dx%d", m.Cols, m.Rows)}
+		}
+		return Event{Type: EventResize, Width: m.Cols, Height: m.Rows}
+	}
+	return Event{Type: EventError, Err: fmt.Errorf("unknown message %q", m.Type)}
+}
+
+// keyEvent gives the event for a key message, as a terminal would have
+// sent it: Ctrl with a letter is the control key, Alt is ModAlt
+func keyEvent(m *Message) Event {
+	ev := Event{Type: EventKey}
+	if m.Alt {
+		ev.Mod |= ModAlt
+	}
+	if k, ok := namedKeys[m.Key]; ok {
+		ev.Key = k
+		if m.Ctrl {
+			ev.Mod |= ModCtrl
+		}
+		if m.Shift {
+			ev.Mod |= ModShift
+		}
+		return ev
+	}
+	r, n := utf8.DecodeRuneInString(m.Key)
+	if n == 0 || n != len(m.Key) || r == utf8.RuneError {
+		return Event{Type: EventNone}
+	}
+	if m.Ctrl {
+		if k, ok := ctrlKey(r); ok {
+			ev.Key = k
+			return ev
+		}
+	}
+	switch {
+	case r == ' ':
+		ev.Key = KeySpace
+	case r < ' ':
+		ev.Key = Key(r)
+	default:
+		ev.Ch = r
+	}
+	return ev
+}
+
+// ctrlKey gives the control key for r typed with Ctrl
+func ctrlKey(r rune) (Key, bool) {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return Key(r - 'a' + 1), true
+	case r >= '@' && r <= '_':
+		return Key(r - '@'), true
+	case r == ' ':
+		return KeyCtrlSpace, true
+	case r == '/':
+		return KeyCtrlSlash, true
+	case r == '?':
+		return KeyCtrl8, true
+	}
+	return 0, false
+}
@@ -0,0 +1,60 @@
+package term
+
+import "testing"
+
+func TestKeyMessages(t *testing.T) {
+	tests := []struct {
+		msg string
+		key Key
+		ch  rune
+		mod Modifier
+	}{
+		{`{"type":"key","key":"a"}`, 0, 'a', 0},
+		{`{"type":"key","key":"A","shift":true}`, 0, 'A', 0},
+		{`{"type":"key","key":"é"}`, 0, 'é', 0},
+		{`{"type":"key","key":"{"}`, 0, '{', 0},
+		{`{"type":"key","key":" "}`, KeySpace, 0, 0},
+		{`{"type":"key","key":"x","ctrl":true}`, KeyCtrlX, 0, 0},
+		{`{"type":"key","key":"S","ctrl":true,"shift":true}`, KeyCtrlS, 0, 0},
+		{`{"type":"key","key":" ","ctrl":true}`, KeyCtrlSpace, 0, 0},
+		{`{"type":"key","key":"f","alt":true}`, 0, 'f', ModAlt},
+		{`{"type":"key","key":"s","ctrl":true,"alt":true}`, KeyCtrlS, 0, ModAlt},
+		{`{"type":"key","key":"Enter"}`, KeyEnter, 0, 0},
+		{`{"type":"key","key":"Backspace"}`, KeyBackspace2, 0, 0},
+		{`{"type":"key","key":"Escape"}`, KeyEsc, 0, 0},
+		{`{"type":"key","key":"ArrowLeft","ctrl":true}`, KeyArrowLeft, 0, ModCtrl},
+		{`{"type":"key","key":"Tab","shift":true}`, KeyTab, 0, ModShift},
+		{`{"type":"key","key":"F12"}`, KeyF12, 0, 0},
+	}
+	for _, tt := range tests {
+		ev := EventFromMessage([]byte(tt.msg))
+		if ev.Type != EventKey || ev.Key != tt.key || ev.Ch != tt.ch || ev.Mod != tt.mod {
+			t.Errorf("%s = %s, want Key(%v) Ch(%v) Mod(%v)", tt.msg, ev.String(), tt.key, tt.ch, tt.mod)
+		}
+	}
+	for _, msg := range []string{`{"type":"key","key":"Shift"}`, `{"type":"key","key":""}`, `{"type":"hello","version":1}`} {
+		if ev := EventFromMessage([]byte(msg)); ev.Type != EventNone {
+			t.Errorf("%s = %s, want none", msg, ev.String())
+		}
+	}
+}
+
+func TestOtherMessages(t *testing.T) {
+	ev := EventFromMessage([]byte(`{"type":"paste","text":"two\nlines"}`))
+	if ev.Type != EventPaste || ev.Text != "two\nlines" {
+		t.Errorf("paste = %s %q", ev.String(), ev.Text)
+	}
+	ev = EventFromMessage([]byte(`{"type":"mouse","action":"press","button":2,"col":4,"row":7}`))
+	if ev.Type != EventMouse || ev.Key != MouseRight || ev.MouseX != 4 || ev.MouseY != 7 {
+		t.Errorf("mouse press = %s at %d,%d", ev.String(), ev.MouseX, ev.MouseY)
+	}
+	ev = EventFromMessage([]byte(`{"type":"mouse","action":"wheelup"}`))
+	if ev.Type != EventMouse || ev.Key != MouseWheelUp {
+		t.Errorf("mouse wheel = %s", ev.String())
+	}
+	for _, bad := range []string{`{"type":"hello","version":2}`, `{"type":"mouse","action":"press","button":5}`} {
+		if ev := EventFromMessage([]byte(bad)); ev.Type != EventError {
+			t.Errorf("%s = %s", bad, ev.String())
+		}
+	}
+}
@@ -1,37 +1,53 @@
 package term
 
 import (
-	"os"
 	"syscall"
 	"unsafe"
 )
 
-func GetTermios(fd uintptr) *syscall.Termios {
-	var t syscall.Termios
-	_, _, err := syscall.Syscall6(
-		syscall.SYS_IOCTL,
-		os.Stdin.Fd(),
-		syscall.TIOCGETA, //TCGETS,
-		uintptr(unsafe.Pointer(&t)),
-		0, 0, 0)
+// the ioctl requests for termios differ between systems, see
+// rawtermios_linux.go and rawtermios_darwin.go
 
+func ioctl(fd, req uintptr, arg unsafe.Pointer) error {
+	_, _, err := syscall.Syscall6(syscall.SYS_IOCTL, fd, req, uintptr(arg), 0, 0, 0)
 	if err != 0 {
-		panic("err")
+		return err
 	}
+	return nil
+}
 
-	return &t
+// GetTermios reads the terminal settings of fd
+func GetTermios(fd uintptr) (*syscall.Termios, error) {
+	var t syscall.Termios
+	if err := ioctl(fd, ioctlGetTermios, unsafe.Pointer(&t)); err != nil {
+		return nil, err
+	}
+	return &t, nil
 }
 
-func SetTermios(fd uintptr, term *syscall.Termios) {
-	_, _, err := syscall.Syscall6(
-		syscall.SYS_IOCTL,
-		os.Stdin.Fd(),
-		syscall.TIOCSETA, //TCSETS,
-		uintptr(unsafe.Pointer(term)),
-		0, 0, 0)
-	if err != 0 {
-		panic("err")
+// SetTermios sets the terminal settings of fd
+func SetTermios(fd uintptr, term *syscall.Termios) error {
+	return ioctl(fd, ioctlSetTermios, unsafe.Pointer(term))
+}
+
+// IsTerminal reports whether fd is a terminal
+func IsTerminal(fd uintptr) bool {
+	_, err := GetTermios(fd)
+	return err == nil
+}
+
+type winsize struct {
+	Row, Col       uint16
+	Xpixel, Ypixel uint16
+}
+
+// GetWinsize gives the columns and rows of the terminal fd
+func GetWinsize(fd uintptr) (cols, rows int, err error) {
+	var ws winsize
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
 	}
+	return int(ws.Col), int(ws.Row), nil
 }
 
 func SetRaw(term *syscall.Termios) {
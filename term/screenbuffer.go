@@ -1,13 +1,24 @@
 package term
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"unicode/utf8"
 )
 
+// Cell is one character on the screen, with its colours and attributes.
+// Fg and Bg are a Color* each, or'ed with Attr* flags.
+type Cell struct {
+	Ch rune
+	Fg Attribute
+	Bg Attribute
+}
+
 type Screen struct {
-	data []rune
+	data []Cell
+	sent []Cell // the screen as it was last sent, nil to send it whole
 	Rows int
 	Cols int
 }
@@ -16,29 +27,45 @@ func NewScreen(c, r int) *Screen {
 	scr := &Screen{}
 	scr.Rows = r
 	scr.Cols = c
-	// make([]rune, C*R)
-	scr.data = make([]rune, c*r)
+	// make([]Cell, C*R)
+	scr.data = make([]Cell, c*r)
 	scr.Blank()
 	log.Println("created ScreenBuf size", len(scr.data))
 	return scr
 }
 
-func (scr *Screen) Blank() {
-	for i, _ := range scr.data {
-		scr.data[i] = ' '
+// Resize makes the screen c columns by r rows, keeping the cells that are
+// on both the old and the new screen
+func (scr *Screen) Resize(c, r int) {
+	if c == scr.Cols && r == scr.Rows {
+		return
+	}
+	old := *scr
+	scr.Cols, scr.Rows = c, r
+	scr.data = make([]Cell, c*r)
+	scr.sent = nil
+	scr.Blank()
+	for row := 0; row < r && row < old.Rows; row++ {
+		for col := 0; col < c && col < old.Cols; col++ {
+			scr.data[scr.rowOrder(col, row)] = old.data[old.rowOrder(col, row)]
+		}
 	}
 }
+
+func (scr *Screen) Blank() {
+	scr.Fill(' ')
+}
 func (scr *Screen) Fill(ru rune) {
-	for i, _ := range scr.data {
-		scr.data[i] = ru
+	for i := range scr.data {
+		scr.data[i] = Cell{Ch: ru}
 	}
 }
 
 func (scr *Screen) checkRange(c, r int) bool {
-	if c > scr.Cols {
+	if c < 0 || c >= scr.Cols {
 		return false
 	}
-	if r > scr.Rows {
+	if r < 0 || r >= scr.Rows {
 		return false
 	}
 	return true
@@ -49,19 +76,27 @@ func (scr *Screen) rowOrder(c, r int) int {
 }
 
 func (scr *Screen) Set(c, r int, ch rune) {
+	scr.SetCell(c, r, ch, ColorDefault, ColorDefault)
+}
+
+// SetCell puts ch at c, r in colours fg and bg
+func (scr *Screen) SetCell(c, r int, ch rune, fg, bg Attribute) {
 	// board[c*C + r] = "abc" // like board[i][j] = "abc"
 	if scr.checkRange(c, r) {
-		//	scr.data[(r*scr.Rows)+c] = ch
-		scr.data[scr.rowOrder(c, r)] = ch
+		scr.data[scr.rowOrder(c, r)] = Cell{ch, fg, bg}
 	}
 }
 
 func (scr *Screen) Get(c, r int) rune {
+	return scr.GetCell(c, r).Ch
+}
+
+// GetCell returns the cell at c, r, a blank one if that is off the screen
+func (scr *Screen) GetCell(c, r int) Cell {
 	if scr.checkRange(c, r) {
-		// return scr.data[(r*scr.Rows)+c]
 		return scr.data[scr.rowOrder(c, r)]
 	}
-	return ' '
+	return Cell{Ch: ' '}
 }
 
 func (scr *Screen) String() string {
@@ -89,7 +124,7 @@ func (scr *Screen) GetBytes() []byte {
 	count := 0
 	for r := 0; r < scr.Rows; r++ {
 		for c := 0; c < scr.Cols; c++ {
-			count += utf8.EncodeRune(buf[count:], scr.data[scr.rowOrder(c, r)])
+			count += utf8.EncodeRune(buf[count:], scr.data[scr.rowOrder(c, r)].Ch)
 		}
 
 	}
@@ -97,3 +132,267 @@ func (scr *Screen) GetBytes() []byte {
 
 	return buf
 }
+
+// the Attr* flags a cell can have
+const attrMask = AttrBold | AttrBlink | AttrHidden | AttrDim | AttrUnderline | AttrCursive | AttrReverse
+
+// ansiColor is the ANSI colour number (0-7, 8-15 for the bright ones)
+// of the Color* in a, -1 for ColorDefault
+func ansiColor(a Attribute) int {
+	c := int(a &^ attrMask)
+	switch {
+	case c >= int(ColorBlack) && c <= int(ColorWhite):
+		return c - int(ColorBlack)
+	case c >= int(ColorDarkGray) && c <= int(ColorLightGray):
+		return c - int(ColorDarkGray) + 8
+	}
+	return -1
+}
+
+// sgr gives the SGR parameters that draw a cell in fg and bg
+func sgr(fg, bg Attribute) []SGRType {
+	ps := []SGRType{SGR_Off}
+	attrs := (fg | bg) & attrMask
+	for _, a := range []struct {
+		attr Attribute
+		sgr  SGRType
+	}{
+		{AttrBold, SGR_Bold},
+		{AttrDim, 2},
+		{AttrCursive, 3},
+		{AttrUnderline, SGR_Underline},
+		{AttrBlink, SGR_Blinking},
+		{AttrReverse, SGR_Negative},
+		{AttrHidden, SGR_Invisible},
+	} {
+		if attrs&a.attr != 0 {
+			ps = append(ps, a.sgr)
+		}
+	}
+	if c := ansiColor(fg); c >= 8 {
+		ps = append(ps, SGRType(90+c-8))
+	} else if c >= 0 {
+		ps = append(ps, SGRType(30+c))
+	}
+	if c := ansiColor(bg); c >= 8 {
+		ps = append(ps, SGRType(100+c-8))
+	} else if c >= 0 {
+		ps = append(ps, SGRType(40+c))
+	}
+	return ps
+}
+
+// ANSI gives the escape sequences that draw the whole screen on a
+// terminal, each row placed with CUP and colours set with SGR
+func (scr *Screen) ANSI() []byte {
+	chs := make([]change, scr.Rows)
+	for r := range chs {
+		chs[r] = change{r, 0, scr.Cols}
+	}
+	return scr.ansi(chs)
+}
+
+// ANSIUpdate gives the escape sequences that bring a terminal showing the
+// screen as it was last sent up to date, and marks it sent. That is only
+// the cells that changed, or the whole screen the first time and after
+// Invalidate or Resize.
+func (scr *Screen) ANSIUpdate() []byte {
+	var b []byte
+	if scr.sent == nil {
+		b = scr.ANSI()
+	} else if chs := scr.changes(); len(chs) > 0 {
+		b = scr.ansi(chs)
+	}
+	scr.MarkSent()
+	return b
+}
+
+// ansi gives the escape sequences that draw the cells of chs
+func (scr *Screen) ansi(chs []change) []byte {
+	var sb strings.Builder
+	var last Cell
+	sb.WriteString(SGR(SGR_Off))
+	for _, ch := range chs {
+		sb.WriteString(CUP(ch.from+1, ch.row+1))
+		for c := ch.from; c < ch.to; c++ {
+			cell := scr.data[scr.rowOrder(c, ch.row)]
+			if cell.Fg != last.Fg || cell.Bg != last.Bg {
+				sb.WriteString(SGR(sgr(cell.Fg, cell.Bg)...))
+				last = cell
+			}
+			sb.WriteRune(cell.Ch)
+		}
+	}
+	sb.WriteString(SGR(SGR_Off))
+	return []byte(sb.String())
+}
+
+// MarkSent notes that the other end now has the screen as it is
+func (scr *Screen) MarkSent() {
+	scr.sent = append(scr.sent[:0], scr.data...)
+}
+
+// Invalidate makes the next update send the whole screen
+func (scr *Screen) Invalidate() {
+	scr.sent = nil
+}
+
+// change is the cells [from, to) of a row that are not as they were sent
+type change struct {
+	row, from, to int
+}
+
+// changeGap is the most unchanged cells between two changes in a row that
+// are sent again to make them one; a new change costs a cursor move
+const changeGap = 4
+
+// changes gives the changes since the screen was last sent
+func (scr *Screen) changes() []change {
+	var chs []change
+	for r := 0; r < scr.Rows; r++ {
+		last := -1
+		for c := 0; c < scr.Cols; c++ {
+			i := scr.rowOrder(c, r)
+			if scr.data[i] == scr.sent[i] {
+				continue
+			}
+			if last >= 0 && c-last <= changeGap {
+				chs[len(chs)-1].to = c + 1
+			} else {
+				chs = append(chs, change{r, c, c + 1})
+			}
+			last = c + 1
+		}
+	}
+	return chs
+}
+
+// Frame bits for the attributes of a Run
+const (
+	FrameBold = 1 << iota
+	FrameUnderline
+	FrameReverse
+	FrameBlink
+	FrameDim
+	FrameItalic
+)
+
+// Run is a stretch of cells, in reading order, with the same colours
+// and attributes. Fg and Bg are ANSI colour numbers, -1 for the default.
+type Run struct {
+	At   int `json:"at"`
+	N    int `json:"n"`
+	Fg   int `json:"fg"`
+	Bg   int `json:"bg"`
+	Attr int `json:"attr"`
+}
+
+// Frame is the screen as sent to the web frontend, a frame message: the
+// text of all the rows one after the other, and the runs of it that are not
+// drawn plain. Cursor is the column and row of the cursor.
+type Frame struct {
+	Type   string `json:"type"`
+	Cols   int    `json:"cols"`
+	Rows   int    `json:"rows"`
+	Text   string `json:"text"`
+	Runs   []Run  `json:"runs"`
+	Cursor [2]int `json:"cursor"`
+}
+
+// Span is a stretch of a row of the screen, its text and the runs of it
+// that are not drawn plain, with Run.At counting from Col
+type Span struct {
+	Row  int    `json:"row"`
+	Col  int    `json:"col"`
+	Text string `json:"text"`
+	Runs []Run  `json:"runs"`
+}
+
+// Damage is what changed on the screen since it was last sent, for the
+// web frontend to draw over the screen it has: a diff message
+type Damage struct {
+	Type   string `json:"type"`
+	Cols   int    `json:"cols"`
+	Rows   int    `json:"rows"`
+	Spans  []Span `json:"spans"`
+	Cursor [2]int `json:"cursor"`
+}
+
+func frameRun(fg, bg Attribute) Run {
+	run := Run{Fg: ansiColor(fg), Bg: ansiColor(bg)}
+	attrs := (fg | bg) & attrMask
+	for _, a := range []struct {
+		attr Attribute
+		bit  int
+	}{
+		{AttrBold, FrameBold},
+		{AttrUnderline, FrameUnderline},
+		{AttrReverse, FrameReverse},
+		{AttrBlink, FrameBlink},
+		{AttrDim, FrameDim},
+		{AttrCursive, FrameItalic},
+	} {
+		if attrs&a.attr != 0 {
+			run.Attr |= a.bit
+		}
+	}
+	return run
+}
+
+// Frame gives the screen as a Frame
+func (scr *Screen) Frame() *Frame {
+	f := &Frame{Type: MsgFrame, Cols: scr.Cols, Rows: scr.Rows}
+	f.Text = string(scr.GetBytes())
+	f.Runs = runs(scr.data)
+	return f
+}
+
+// runs gives the runs of cells that are not drawn plain
+func runs(cells []Cell) []Run {
+	rs := []Run{}
+	var cur Run
+	flush := func() {
+		if cur.N > 0 && (cur.Fg != -1 || cur.Bg != -1 || cur.Attr != 0) {
+			rs = append(rs, cur)
+		}
+	}
+	for i, cell := range cells {
+		run := frameRun(cell.Fg, cell.Bg)
+		if cur.N > 0 && run.Fg == cur.Fg && run.Bg == cur.Bg && run.Attr == cur.Attr {
+			cur.N++
+			continue
+		}
+		flush()
+		cur = run
+		cur.At, cur.N = i, 1
+	}
+	flush()
+	return rs
+}
+
+// Damage gives the changes since the screen was last sent, nil if it has
+// to be sent whole
+func (scr *Screen) Damage() *Damage {
+	if scr.sent == nil {
+		return nil
+	}
+	d := &Damage{Type: MsgDiff, Cols: scr.Cols, Rows: scr.Rows, Spans: []Span{}}
+	for _, ch := range scr.changes() {
+		cells := scr.data[scr.rowOrder(ch.from, ch.row):scr.rowOrder(ch.to, ch.row)]
+		text := make([]rune, len(cells))
+		for i, cell := range cells {
+			text[i] = cell.Ch
+		}
+		d.Spans = append(d.Spans, Span{ch.row, ch.from, string(text), runs(cells)})
+	}
+	return d
+}
+
+// FrameBytes gives the screen as a JSON Frame
+func (scr *Screen) FrameBytes() []byte {
+	b, err := json.Marshal(scr.Frame())
+	if err != nil {
+		log.Println("unable to encode screen", err)
+	}
+	return b
+}
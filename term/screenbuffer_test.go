@@ -1,6 +1,12 @@
 package term
 
-import "testing"
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
 
 func TestBufCreate(t *testing.T) {
 	c, r := 6, 4
@@ -66,3 +72,156 @@ func TestBufSet3(t *testing.T) {
 		t.Errorf("result |%s|(%d) %d, %d", sr, len(sr), c, r)
 	}
 }
+
+func TestSetCellAttrs(t *testing.T) {
+	scr := NewScreen(4, 2)
+	scr.SetCell(1, 0, 'x', ColorRed|AttrBold, ColorDefault)
+	scr.SetCell(4, 0, 'y', ColorRed, ColorDefault) // off the right edge
+	scr.SetCell(0, -1, 'y', ColorRed, ColorDefault)
+	if got := scr.GetCell(1, 0); got != (Cell{'x', ColorRed | AttrBold, ColorDefault}) {
+		t.Errorf("GetCell(1, 0) = %v", got)
+	}
+	if got := scr.Get(0, 1); got != ' ' {
+		t.Errorf("SetCell past the edge wrote into the next row: %q", got)
+	}
+	scr.Blank()
+	if got := scr.GetCell(1, 0); got != (Cell{Ch: ' '}) {
+		t.Errorf("Blank left %v", got)
+	}
+}
+
+func TestANSIColor(t *testing.T) {
+	for a, want := range map[Attribute]int{
+		ColorDefault:               -1,
+		ColorBlack:                 0,
+		ColorWhite:                 7,
+		ColorDarkGray:              8,
+		ColorLightGray:             15,
+		ColorBlue | AttrReverse:    4,
+		AttrBold | AttrUnderline:   -1,
+		ColorLightCyan | AttrBlink: 14,
+	} {
+		if got := ansiColor(a); got != want {
+			t.Errorf("ansiColor(%x) = %d, want %d", a, got, want)
+		}
+	}
+}
+
+func TestScreenANSI(t *testing.T) {
+	scr := NewScreen(3, 2)
+	scr.SetCell(1, 0, 'b', ColorGreen|AttrUnderline, ColorDefault)
+	scr.SetCell(2, 0, 'c', ColorGreen|AttrUnderline, ColorDefault)
+	scr.SetCell(0, 1, 'd', ColorBlack, ColorLightYellow|AttrReverse)
+	want := "\x1b[0m\x1b[1;1H \x1b[0;4;32mbc\x1b[2;1H\x1b[0;7;30;103md\x1b[0m  \x1b[0m"
+	if got := string(scr.ANSI()); got != want {
+		t.Errorf("ANSI() = %q, want %q", got, want)
+	}
+}
+
+func TestScreenFrame(t *testing.T) {
+	scr := NewScreen(3, 2)
+	scr.Set(0, 0, 'α')
+	scr.SetCell(1, 0, 'b', ColorDefault|AttrReverse, ColorDefault)
+	scr.SetCell(2, 0, 'c', ColorDefault|AttrReverse, ColorDefault)
+	scr.SetCell(0, 1, 'd', ColorCyan|AttrBold, ColorDefault)
+	want := `{"type":"frame","cols":3,"rows":2,"text":"αbcd  ","runs":[{"at":1,"n":2,"fg":-1,"bg":-1,"attr":4},{"at":3,"n":1,"fg":6,"bg":-1,"attr":1}],"cursor":[0,0]}`
+	if got := string(scr.FrameBytes()); got != want {
+		t.Errorf("FrameBytes() = %s, want %s", got, want)
+	}
+	if got := len(NewScreen(2, 2).Frame().Runs); got != 0 {
+		t.Errorf("blank screen has %d runs", got)
+	}
+}
+
+func TestScreenResize(t *testing.T) {
+	scr := NewScreen(4, 2)
+	scr.Fill('x')
+	scr.SetCell(3, 1, 'y', ColorRed, ColorDefault)
+	scr.Resize(6, 3)
+	if scr.Cols != 6 || scr.Rows != 3 || len(scr.data) != 18 {
+		t.Errorf("Resize to 6x3 gave %dx%d, %d cells", scr.Cols, scr.Rows, len(scr.data))
+	}
+	if got := string(scr.GetBytes()); got != "xxxx  xxxy        " {
+		t.Errorf("after growing %q", got)
+	}
+	if c := scr.GetCell(3, 1); c.Fg != ColorRed {
+		t.Errorf("cell lost its colour %v", c)
+	}
+	scr.Resize(2, 1)
+	if got := string(scr.GetBytes()); got != "xx" {
+		t.Errorf("after shrinking %q", got)
+	}
+}
+
+func TestScreenANSIUpdate(t *testing.T) {
+	scr := NewScreen(12, 2)
+	if got := scr.ANSIUpdate(); string(got) != string(scr.ANSI()) {
+		t.Errorf("first update %q is not the whole screen", got)
+	}
+	if got := scr.ANSIUpdate(); len(got) != 0 {
+		t.Errorf("update with no changes = %q", got)
+	}
+	scr.Set(1, 0, 'a')
+	scr.Set(3, 0, 'b')                              // close enough to join the change before
+	scr.SetCell(10, 0, 'c', ColorRed, ColorDefault) // too far
+	scr.Set(0, 1, 'd')
+	want := "\x1b[0m\x1b[1;2Ha b\x1b[1;11H\x1b[0;31mc\x1b[2;1H\x1b[0md\x1b[0m"
+	if got := string(scr.ANSIUpdate()); got != want {
+		t.Errorf("ANSIUpdate() = %q, want %q", got, want)
+	}
+	scr.Invalidate()
+	if got := scr.ANSIUpdate(); string(got) != string(scr.ANSI()) {
+		t.Errorf("update after Invalidate %q is not the whole screen", got)
+	}
+}
+
+func TestScreenDamage(t *testing.T) {
+	scr := NewScreen(10, 3)
+	if scr.Damage() != nil {
+		t.Errorf("Damage before the screen was first sent")
+	}
+	scr.MarkSent()
+	scr.Set(2, 1, 'x')
+	scr.SetCell(3, 1, 'y', ColorDefault|AttrReverse, ColorDefault)
+	d := scr.Damage()
+	b, _ := json.Marshal(d)
+	want := `{"type":"diff","cols":10,"rows":3,"spans":[{"row":1,"col":2,"text":"xy","runs":[{"at":1,"n":1,"fg":-1,"bg":-1,"attr":4}]}],"cursor":[0,0]}`
+	if string(b) != want {
+		t.Errorf("Damage() = %s, want %s", b, want)
+	}
+	scr.MarkSent()
+	if d := scr.Damage(); len(d.Spans) != 0 {
+		t.Errorf("Damage after MarkSent = %v", d.Spans)
+	}
+	scr.Resize(5, 3)
+	if scr.Damage() != nil {
+		t.Errorf("Damage after Resize")
+	}
+}
+
+func TestFlushOnlyChanges(t *testing.T) {
+	var out bytes.Buffer
+	term := &Term{Kind: Pty, Output: bufio.NewWriter(&out), ScrBuf: NewScreen(8, 2)}
+	term.Flush()
+	if !strings.Contains(out.String(), string(term.ScrBuf.ANSI())) {
+		t.Errorf("first Flush %q", out.String())
+	}
+	out.Reset()
+	term.Flush()
+	if out.Len() != 0 {
+		t.Errorf("Flush with nothing changed sent %q", out.String())
+	}
+	term.SetCell(4, 1, 'z', ColorDefault, ColorDefault)
+	term.SetCursor(5, 1)
+	term.Flush()
+	want := CURHIDE() + "\x1b[0m\x1b[2;5Hz\x1b[0m" + CUP(6, 2) + CURSHOW()
+	if out.String() != want {
+		t.Errorf("Flush = %q, want %q", out.String(), want)
+	}
+	out.Reset()
+	term.SetCursor(0, 0)
+	term.Flush()
+	if want := CURHIDE() + CUP(1, 1) + CURSHOW(); out.String() != want {
+		t.Errorf("Flush of a cursor move = %q, want %q", out.String(), want)
+	}
+}
@@ -2,9 +2,11 @@ package term
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
 	"syscall"
 	"unicode/utf8"
@@ -34,31 +36,55 @@ type Term struct {
 	Conn     *websocket.Conn
 	CurCol   int
 	CurRow   int
+
+	inbytes    chan []byte    // what startInput has read
+	inerr      error          // why startInput stopped
+	inclosed   bool           // inbytes is closed
+	pending    []byte         // read but not yet made into events
+	winch      chan os.Signal // SIGWINCH, the terminal changed size
+	repaint    bool           // the next Flush clears the terminal first
+	sentCol    int            // where the cursor was at the last Flush
+	sentRow    int
+	title      string // see SetTitle
+	status     string // see SetStatus
+	bell       bool   // ring the bell at the next Flush
+	sentTitle  string // the title and status as at the last Flush
+	sentStatus string
 }
 
-// this `term` imeplmentation only really does teh Web type.
-// the Pty type of term has not been finished or tested.
+// NewTerm makes a terminal of kind. A Pty terminal puts the controlling
+// terminal into raw mode on the alternate screen, and is as big as the
+// terminal is; call Cleanup to put it back the way it was.
 func NewTerm(kind TermType) *Term {
 	t := &Term{}
 	t.Kind = kind
+	cols, rows := 80, 24 // termsize cols, rows
 
 	if kind == Pty {
 		t.Input = bufio.NewReader(os.Stdin)
 		t.Output = bufio.NewWriter(os.Stdout)
 		stdin := os.Stdin.Fd()
-		termios := GetTermios(stdin)
-
-		t.Origin = termios
-
-		SetRaw(termios)
-		SetTermios(stdin, termios)
-		t.Output.Write([]byte(CSIStart()))
-
+		termios, err := GetTermios(stdin)
+		if err != nil {
+			log.Println("stdin is not a terminal:", err)
+		} else {
+			t.Origin = termios
+			raw := *termios
+			SetRaw(&raw)
+			if err := SetTermios(stdin, &raw); err != nil {
+				log.Println("unable to set raw mode:", err)
+			}
+			t.Output.WriteString(PushTitle() + SMCUP() + ED(EraseAll))
+			t.Output.Flush()
+			t.winch = make(chan os.Signal, 1)
+			signal.Notify(t.winch, syscall.SIGWINCH)
+		}
+		if c, r, err := GetWinsize(os.Stdout.Fd()); err == nil && c > 0 && r > 0 {
+			cols, rows = c, r
+		}
 	}
 
-	if kind == Web {
-		t.ScrBuf = NewScreen(80, 24) // termsize cols, rows
-	}
+	t.ScrBuf = NewScreen(cols, rows)
 
 	return t
 }
@@ -66,23 +92,18 @@ func NewTerm(kind TermType) *Term {
 func (t *Term) IsPty() bool { return t.Kind == Pty }
 func (t *Term) IsWeb() bool { return t.Kind == Web }
 
+// Cleanup gives the terminal back as NewTerm found it. It is safe to call
+// more than once, so it can be deferred and also run on a signal.
 func (t *Term) Cleanup() {
-	if t.IsPty() {
-		t.Output.Write([]byte(CSIStart()))
-		SetTermios(os.Stdin.Fd(), t.Origin)
-	}
-}
-
-func (t *Term) PollEvent() Event {
-	ru, _, err := t.Input.ReadRune()
-	//log.Println("Event recv", ru)
-	if err != nil {
-		panic(err)
+	if t.IsPty() && t.Origin != nil {
+		signal.Stop(t.winch)
+		t.Output.WriteString(SGR(SGR_Off) + CURSHOW() + RMCUP() + PopTitle())
+		t.Output.Flush()
+		if err := SetTermios(os.Stdin.Fd(), t.Origin); err != nil {
+			log.Println("unable to restore terminal:", err)
+		}
+		t.Origin = nil
 	}
-	e := Event{}
-	e.Type = EventKey
-	e.Ch = ru
-	return e
 }
 
 func (t *Term) EventFromByte(b byte) Event {
@@ -93,27 +114,14 @@ func (t *Term) EventFromByte(b byte) Event {
 }
 func (t *Term) EventFromKey(key []byte) Event {
 	//log.Println("EventFromKey", len(key), key)
-	// this case is when the arrow keys come in.
-	// "27,91,{65,66,67,68}"
-	if len(key) == 3 && key[0] == 27 && key[1] == 91 {
-		e := Event{}
-		e.Type = EventKey
-		e.Ch = 0
-		switch key[2] {
-		case 65:
-			e.Key = KeyArrowUp
-			return e
-		case 66:
-			e.Key = KeyArrowDown
-			return e
-		case 67:
-			e.Key = KeyArrowRight
-			return e
-		case 68:
-			e.Key = KeyArrowLeft
-			return e
-		}
-		// if it ain't one of the four, do normal process
+	// "{" on its own is a key, anything longer is a message
+	if len(key) > 1 && key[0] == '{' {
+		return EventFromMessage(key)
+	}
+	// keys without a character come in as escape sequences, "\x1b[A"
+	if len(key) > 1 && key[0] == esc {
+		ev, _ := ParseKey(key, false)
+		return ev
 	}
 	// otherwise decode the rune (possibly multi-byte)
 	ru, n := utf8.DecodeRune(key)
@@ -148,7 +156,7 @@ func (t *Term) Write(b []byte) {
 		t.Output.Write(b)
 		t.Output.Flush()
 	}
-	if t.Kind == Web {
+	if t.Kind == Web && t.Conn != nil {
 		msgType := 1
 		msg := b
 		if err := t.Conn.WriteMessage(msgType, msg); err != nil {
@@ -162,41 +170,132 @@ func (t *Term) Blank() {
 	t.ScrBuf.Blank()
 }
 
+// Flush sends what changed on the screen since the last Flush, where the
+// cursor is, and the title, status and bell if they changed, to the
+// terminal or the web frontend. Nothing is sent if nothing changed.
 func (t *Term) Flush() {
-
-	if t.IsWeb() {
-		//log.Printf("\nOnFlush***\n%s***\n", t.ScrBuf.String())
-		msgType := 1
-		msg := t.ScrBuf.GetBytes()
-		if err := t.Conn.WriteMessage(msgType, msg); err != nil {
-			log.Println("unable to write message to frontend")
+	moved := t.CurCol != t.sentCol || t.CurRow != t.sentRow
+	if t.IsPty() {
+		if t.repaint {
+			t.Output.WriteString(SGR(SGR_Off) + ED(EraseAll))
+			t.repaint = false
+		}
+		if t.title != t.sentTitle {
+			t.Output.WriteString(OSCTitle(t.title))
+		}
+		if t.bell {
+			t.Output.WriteString("\a")
+		}
+		b := t.ScrBuf.ANSIUpdate()
+		if len(b) == 0 && !moved {
+			t.Output.Flush()
+			t.sentTitle, t.bell = t.title, false
 			return
 		}
+		t.Output.WriteString(CURHIDE())
+		t.Output.Write(b)
+		t.Output.WriteString(CUP(t.CurCol+1, t.CurRow+1) + CURSHOW())
+		t.Output.Flush()
+	}
+	if t.IsWeb() && t.Conn != nil {
+		//log.Printf("\nOnFlush***\n%s***\n", t.ScrBuf.String())
+		cursor := [2]int{t.CurCol, t.CurRow}
+		if d := t.ScrBuf.Damage(); d == nil {
+			f := t.ScrBuf.Frame()
+			f.Cursor = cursor
+			t.send(f)
+		} else if len(d.Spans) > 0 {
+			d.Cursor = cursor
+			t.send(d)
+		} else if moved {
+			t.send(Message{Type: MsgCursor, Cursor: &cursor})
+		}
+		t.ScrBuf.MarkSent()
+		if t.title != t.sentTitle {
+			t.send(Message{Type: MsgTitle, Text: t.title})
+		}
+		if t.status != t.sentStatus {
+			t.send(Message{Type: MsgStatus, Text: t.status})
+		}
+		if t.bell {
+			t.send(Message{Type: MsgBell})
+		}
+	}
+	t.sentCol, t.sentRow = t.CurCol, t.CurRow
+	t.sentTitle, t.sentStatus = t.title, t.status
+	t.bell = false
+}
+
+// send writes v to the web frontend as a JSON message
+func (t *Term) send(v interface{}) {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		log.Println("unable to encode message", err)
+		return
 	}
+	if err := t.Conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		log.Println("unable to write message to frontend")
+	}
+}
 
+// SetTitle sets the title of the terminal window, or of the browser's tab
+func (t *Term) SetTitle(title string) {
+	t.title = title
+}
+
+// SetStatus tells the web frontend what the message line says, for it to
+// show where it wants to as well as on the screen
+func (t *Term) SetStatus(status string) {
+	t.status = status
+}
+
+// Bell rings the bell with the next Flush
+func (t *Term) Bell() {
+	t.bell = true
+}
+
+// Repaint makes the next Flush draw the whole screen afresh, and send
+// the title and status again
+func (t *Term) Repaint() {
+	t.repaint = t.IsPty()
+	t.ScrBuf.Invalidate()
+	t.sentTitle, t.sentStatus = "", ""
 }
 
 func (t *Term) Clear() {
 	t.Blank()
 }
 
+// Size gives the columns and rows of the screen
 func (t *Term) Size() (int, int) {
-	return 80, 24 // termsize cols, rows;
+	return t.ScrBuf.Cols, t.ScrBuf.Rows
+}
+
+// Resize makes the screen cols by rows, after the terminal or the browser
+// window it is in changed size
+func (t *Term) Resize(cols, rows int) {
+	t.ScrBuf.Resize(cols, rows)
+	t.Repaint()
+}
+
+// resizeEvent is the EventResize for the size the terminal is now
+func (t *Term) resizeEvent() Event {
+	c, r, err := GetWinsize(os.Stdout.Fd())
+	if err != nil {
+		return Event{Type: EventError, Err: err}
+	}
+	return Event{Type: EventResize, Width: c, Height: r}
 }
 
 func (t *Term) SetCell(c, r int, ch rune, fg, bg Attribute) {
-	// switch zero-based to one-based?
-	t.ScrBuf.Set(c, r, ch)
+	t.ScrBuf.SetCell(c, r, ch, fg, bg)
 }
 func (t *Term) SetCursor(c int, r int) {
 	//log.Println("term.SetCursor", c, r)
 	// switch zero-based to one-based?
+	// the cursor is sent with the screen on Flush
 	t.CurCol = c
 	t.CurRow = r
-	if t.IsWeb() {
-		// t.Write([]byte(CUP(t.CurCol, t.CurRow)))
-		t.Write([]byte(CUP(t.CurCol, t.CurRow)))
-	}
 }
 
 // ANSI CSI term codes
@@ -220,12 +319,41 @@ func CSIEnd() string {
 // func CURBLK() string {
 // 	return (fmt.Sprintf("%s0 q", CSI))
 // }
-// func CURSHOW() string {
-// 	return (fmt.Sprintf("%s?25h", CSI))
-// }
-// func CURHIDE() string {
-// 	return (fmt.Sprintf("%s?25l", CSI))
-// }
+
+// CURSHOW - show the cursor
+func CURSHOW() string {
+	return (fmt.Sprintf("%s?25h", CSI))
+}
+
+// CURHIDE - hide the cursor
+func CURHIDE() string {
+	return (fmt.Sprintf("%s?25l", CSI))
+}
+
+// SMCUP - switch to the alternate screen, saving the normal one
+func SMCUP() string {
+	return (fmt.Sprintf("%s?1049h", CSI))
+}
+
+// RMCUP - go back to the normal screen
+func RMCUP() string {
+	return (fmt.Sprintf("%s?1049l", CSI))
+}
+
+// OSCTitle - set the window title
+func OSCTitle(title string) string {
+	return fmt.Sprintf("\x1b]0;%s\a", title)
+}
+
+// PushTitle - save the window title, for PopTitle
+func PushTitle() string {
+	return (fmt.Sprintf("%s22;0t", CSI))
+}
+
+// PopTitle - put back the window title PushTitle saved
+func PopTitle() string {
+	return (fmt.Sprintf("%s23;0t", CSI))
+}
 
 // func DECSET(n int) string {
 // 	return (fmt.Sprintf("%s?%dh", CSI, n))
@@ -0,0 +1,155 @@
+package web
+
+import (
+	"crypto/subtle"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+/*
+ * With a Token set, the server is for those who know it. A browser logs in
+ * at /login, or opens a link with ?token= once, and keeps a cookie; other
+ * clients give the token as "Authorization: Bearer <token>" with the
+ * websocket handshake. Everything but the login page needs one or the
+ * other. The cookie holds a random key the server makes when it starts,
+ * not the token, so restarting the server logs everyone out.
+ */
+
+// loginCookie is the cookie a browser keeps once it has logged in
+const loginCookie = "ke-login"
+
+// loginDelay slows down guessing the token
+var loginDelay = time.Second
+
+// goodToken tells whether token is the server's Token
+func (editor *EditorServer) goodToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(editor.Token)) == 1
+}
+
+// loggedIn tells whether r comes from someone who has logged in, with the
+// cookie or the token. Anyone has when the server has no Token.
+func (editor *EditorServer) loggedIn(r *http.Request) bool {
+	if editor.Token == "" {
+		return true
+	}
+	if c, err := r.Cookie(loginCookie); err == nil &&
+		subtle.ConstantTimeCompare([]byte(c.Value), []byte(editor.loginKey)) == 1 {
+		return true
+	}
+	auth := r.Header.Get("Authorization")
+	return strings.HasPrefix(auth, "Bearer ") && editor.goodToken(strings.TrimPrefix(auth, "Bearer "))
+}
+
+// logIn gives the browser the login cookie
+func (editor *EditorServer) logIn(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginCookie,
+		Value:    editor.loginKey,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// requireLogin sends anyone who has not logged in to the login page, or
+// turns away a websocket with 401. A good ?token= logs the browser in, or
+// lets a websocket straight in, as a redirect would fail its handshake.
+func (editor *EditorServer) requireLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("token"); token != "" && editor.Token != "" {
+			if r.URL.Path == "/editor" {
+				if !editor.goodToken(token) {
+					time.Sleep(loginDelay)
+					http.Error(w, "not logged in", http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !editor.goodToken(token) {
+				time.Sleep(loginDelay)
+				http.Redirect(w, r, "/login#failed", http.StatusSeeOther)
+				return
+			}
+			// take the token out of the address bar
+			editor.logIn(w, r)
+			u := *r.URL
+			q := u.Query()
+			q.Del("token")
+			u.RawQuery = q.Encode()
+			http.Redirect(w, r, u.RequestURI(), http.StatusSeeOther)
+			return
+		}
+		if editor.loggedIn(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == "/editor" {
+			http.Error(w, "not logged in", http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	})
+}
+
+// login serves the login page, and logs in with the token posted from it
+func (editor *EditorServer) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		editor.serveStatic(w, r, "login.html")
+		return
+	}
+	if editor.Token == "" || !editor.goodToken(r.PostFormValue("token")) {
+		log.Println("failed login from", r.RemoteAddr)
+		time.Sleep(loginDelay)
+		http.Redirect(w, r, "/login#failed", http.StatusSeeOther)
+		return
+	}
+	editor.logIn(w, r)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// checkOrigin lets a websocket in from a page of this server or of one of
+// Origins, or from a client that is not a browser, which gives no Origin.
+// A page is only taken to be this server's when it was loaded by a name
+// that must be this server: see ownHost.
+func (editor *EditorServer) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) && editor.ownHost(r.Host) {
+		return true
+	}
+	for _, o := range editor.Origins {
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	log.Println("refused websocket from", origin)
+	return false
+}
+
+// ownHost tells whether host, from a request's Host header, can only be
+// this server: an IP address, localhost, or the host it listens on. Any
+// other name might be an attacker's, made to point at this server by its
+// DNS (DNS rebinding), so that its pages pass for the server's own; such
+// a name has to be one of Origins.
+func (editor *EditorServer) ownHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if strings.EqualFold(host, "localhost") || net.ParseIP(host) != nil {
+		return true
+	}
+	if editor.Server != nil {
+		listen, _, err := net.SplitHostPort(editor.Server.Addr)
+		return err == nil && listen != "" && strings.EqualFold(listen, host)
+	}
+	return false
+}
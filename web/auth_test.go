@@ -0,0 +1,180 @@
+package web
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newTokenServer serves an editor that wants token
+func newTokenServer(token string) (*EditorServer, *httptest.Server) {
+	loginDelay = 0
+	es := NewEditorServer()
+	es.Token = token
+	return es, httptest.NewServer(es.Handler())
+}
+
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/editor"
+}
+
+func TestLoginRequired(t *testing.T) {
+	_, srv := newTokenServer("sesame")
+	defer srv.Close()
+
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noFollow.Get(srv.URL + "/static/vt100.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/login" {
+		t.Errorf("not logged in got %s to %q", resp.Status, resp.Header.Get("Location"))
+	}
+
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL(srv), nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("websocket without the token: %v", err)
+	}
+	if _, err := DialToken(wsURL(srv), "", "open"); err == nil {
+		t.Errorf("websocket with the wrong token")
+	}
+	c, err := DialToken(wsURL(srv), "", "sesame")
+	if err != nil {
+		t.Fatalf("websocket with the token: %v", err)
+	}
+	c.Close()
+
+	// a stale cookie does not stand in the way of the token
+	header := http.Header{}
+	header.Set("Cookie", loginCookie+"=stale")
+	header.Set("Authorization", "Bearer sesame")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), header)
+	if err != nil {
+		t.Fatalf("websocket with a stale cookie and the token: %v", err)
+	}
+	conn.Close()
+
+	// nor is a websocket redirected for a token in its address
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL(srv)+"?token=open", nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("websocket with the wrong ?token=: %v", err)
+	}
+	conn, _, err = websocket.DefaultDialer.Dial(wsURL(srv)+"?token=sesame", nil)
+	if err != nil {
+		t.Fatalf("websocket with ?token=: %v", err)
+	}
+	conn.Close()
+}
+
+func TestLogin(t *testing.T) {
+	_, srv := newTokenServer("sesame")
+	defer srv.Close()
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar}
+
+	resp, err := browser.PostForm(srv.URL+"/login", url.Values{"token": {"open"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Request.URL.Path != "/login" {
+		t.Errorf("wrong token got to %s", resp.Request.URL)
+	}
+
+	resp, err = browser.PostForm(srv.URL+"/login", url.Values{"token": {"sesame"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.Path != "/" {
+		t.Errorf("login got %s at %s", resp.Status, resp.Request.URL)
+	}
+
+	// the websocket handshake sends the cookie, as a browser's does
+	u, _ := url.Parse(srv.URL)
+	header := http.Header{}
+	for _, c := range jar.Cookies(u) {
+		header.Add("Cookie", c.String())
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), header)
+	if err != nil {
+		t.Fatalf("websocket after login: %v", err)
+	}
+	conn.Close()
+}
+
+func TestTokenLink(t *testing.T) {
+	_, srv := newTokenServer("sesame")
+	defer srv.Close()
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar}
+
+	resp, err := browser.Get(srv.URL + "/vt100?token=sesame")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Request.URL.String() != srv.URL+"/vt100" {
+		t.Errorf("token link got %s at %s", resp.Status, resp.Request.URL)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	es, srv := newTokenServer("")
+	defer srv.Close()
+	es.Origins = []string{"https://ke.example.com"}
+
+	for origin, ok := range map[string]bool{
+		"":                        true,
+		srv.URL:                   true,
+		"https://ke.example.com":  true,
+		"http://evil.example.com": false,
+	} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), header)
+		if (err == nil) != ok {
+			t.Errorf("websocket from %q: %v", origin, err)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
+
+func TestCheckOriginRebinding(t *testing.T) {
+	es, srv := newTokenServer("")
+	defer srv.Close()
+	es.Origins = []string{"http://ke.example.com:8005"}
+	es.Server.Addr = "devbox.example:8005"
+
+	// a page from a name the attacker's DNS points here gives matching
+	// Origin and Host
+	for host, ok := range map[string]bool{
+		"evil.example:8005":   false,
+		"localhost:8005":      true,
+		"127.0.0.1:8005":      true,
+		"[::1]:8005":          true,
+		"ke.example.com:8005": true,
+		"devbox.example:8005": true,
+	} {
+		header := http.Header{}
+		header.Set("Host", host)
+		header.Set("Origin", "http://"+host)
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv), header)
+		if (err == nil) != ok {
+			t.Errorf("websocket from a page of %q: %v", host, err)
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kristofer/ke/term"
+
+	"github.com/gorilla/websocket"
+)
+
+// Client is the browser's end of the protocol, in Go, for tests and
+// tools. It says hello, sends keys and the like, and keeps a copy of the
+// screen from the frames and diffs it reads.
+type Client struct {
+	Conn    *websocket.Conn
+	Session string // the session's token, from the editor's hello
+	Cols    int
+	Rows    int
+	Cursor  [2]int
+	Title   string
+	Status  string
+	Bells   int    // how many times the bell has rung
+	screen  []rune // Rows rows of Cols
+}
+
+// Dial connects to the editor websocket at url, resuming the session with
+// token session if it is not "", and reads the editor's hello
+func Dial(url, session string) (*Client, error) {
+	return dial(url, session, nil)
+}
+
+// DialToken is Dial for a server that wants its token to log in
+func DialToken(url, session, token string) (*Client, error) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer "+token)
+	return dial(url, session, h)
+}
+
+func dial(url, session string, header http.Header) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{Conn: conn}
+	hello := term.Message{Type: term.MsgHello, Version: term.ProtocolVersion, Session: session}
+	if err := c.Send(hello); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	m, err := c.Read()
+	if err == nil && m.Type != term.MsgHello {
+		err = fmt.Errorf("%q before hello", m.Type)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.Session = m.Session
+	return c, nil
+}
+
+// Send sends m to the editor
+func (c *Client) Send(m term.Message) error {
+	return c.Conn.WriteJSON(m)
+}
+
+// Key sends key, named as a browser names it ("a", "Enter", "ArrowLeft"),
+// with the modifiers in mod
+func (c *Client) Key(key string, mod term.Modifier) error {
+	return c.Send(term.Message{
+		Type:  term.MsgKey,
+		Key:   key,
+		Ctrl:  mod&term.ModCtrl != 0,
+		Alt:   mod&term.ModAlt != 0,
+		Shift: mod&term.ModShift != 0,
+	})
+}
+
+// Type sends text a key at a time, a newline as Enter
+func (c *Client) Type(text string) error {
+	for _, r := range text {
+		key := string(r)
+		if r == '\n' {
+			key = "Enter"
+		}
+		if err := c.Key(key, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Paste sends text as pasted
+func (c *Client) Paste(text string) error {
+	return c.Send(term.Message{Type: term.MsgPaste, Text: text})
+}
+
+// Resize tells the editor the screen is cols by rows
+func (c *Client) Resize(cols, rows int) error {
+	return c.Send(term.Message{Type: term.MsgResize, Cols: cols, Rows: rows})
+}
+
+// Click presses and releases the left button at col, row
+func (c *Client) Click(col, row int) error {
+	if err := c.Send(term.Message{Type: term.MsgMouse, Action: "press", Col: col, Row: row}); err != nil {
+		return err
+	}
+	return c.Send(term.Message{Type: term.MsgMouse, Action: "release", Col: col, Row: row})
+}
+
+// Read reads the next message from the editor and keeps what it says. An
+// error message is given back as an error, with the message.
+func (c *Client) Read() (*term.Message, error) {
+	_, b, err := c.Conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var m term.Message
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	switch m.Type {
+	case term.MsgFrame:
+		c.resize(m.Cols, m.Rows)
+		copy(c.screen, []rune(m.Text))
+	case term.MsgDiff:
+		c.resize(m.Cols, m.Rows)
+		for _, span := range m.Spans {
+			for i, r := range []rune(span.Text) {
+				if span.Row < c.Rows && span.Col+i < c.Cols {
+					c.screen[span.Row*c.Cols+span.Col+i] = r
+				}
+			}
+		}
+	case term.MsgTitle:
+		c.Title = m.Text
+	case term.MsgStatus:
+		c.Status = m.Text
+	case term.MsgBell:
+		c.Bells++
+	case term.MsgError:
+		return &m, fmt.Errorf("editor: %s", m.Text)
+	}
+	if m.Cursor != nil {
+		c.Cursor = *m.Cursor
+	}
+	return &m, nil
+}
+
+// WaitFor reads messages until ok is true of the client, for up to
+// timeout
+func (c *Client) WaitFor(timeout time.Duration, ok func(c *Client) bool) error {
+	c.Conn.SetReadDeadline(time.Now().Add(timeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+	for !ok(c) {
+		if _, err := c.Read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resize makes the screen cols by rows, blank if its size changed
+func (c *Client) resize(cols, rows int) {
+	if cols == c.Cols && rows == c.Rows {
+		return
+	}
+	c.Cols, c.Rows = cols, rows
+	c.screen = []rune(strings.Repeat(" ", cols*rows))
+}
+
+// Line gives row of the screen, with the spaces it ends in
+func (c *Client) Line(row int) string {
+	if row < 0 || row >= c.Rows {
+		return ""
+	}
+	return string(c.screen[row*c.Cols : (row+1)*c.Cols])
+}
+
+// Screen gives the screen, a line for each row
+func (c *Client) Screen() string {
+	var sb strings.Builder
+	for r := 0; r < c.Rows; r++ {
+		sb.WriteString(strings.TrimRight(c.Line(r), " "))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Close says goodbye and closes the websocket
+func (c *Client) Close() error {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	c.Conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	return c.Conn.Close()
+}
@@ -0,0 +1,67 @@
+package web
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kristofer/ke/term"
+)
+
+func TestProtocol(t *testing.T) {
+	es := NewEditorServer()
+	srv := httptest.NewServer(es.Handler())
+	defer srv.Close()
+	c := dialEditor(t, srv, "")
+	defer c.Close()
+	wait := func(what string, ok func(c *Client) bool) {
+		t.Helper()
+		if err := c.WaitFor(2*time.Second, ok); err != nil {
+			t.Fatalf("%s: %v\n%s", what, err, c.Screen())
+		}
+	}
+
+	wait("title", func(c *Client) bool { return c.Title == "*scratch* - kg" })
+	if c.Cols != 80 || c.Rows != 24 {
+		t.Errorf("first screen %dx%d", c.Cols, c.Rows)
+	}
+	c.Resize(40, 10)
+	wait("resize", func(c *Client) bool { return c.Cols == 40 && c.Rows == 10 })
+
+	c.Paste("one\r\ntwo ")
+	wait("paste", func(c *Client) bool {
+		return strings.HasPrefix(c.Line(1), "two 1 foo") && c.Title == "*scratch* (modified) - kg" && c.Status == ""
+	})
+	if c.Cursor != [2]int{4, 1} {
+		t.Errorf("after paste cursor at %v", c.Cursor)
+	}
+
+	c.Click(2, 0)
+	wait("click", func(c *Client) bool { return c.Cursor == [2]int{2, 0} })
+
+	c.Key("g", term.ModCtrl)
+	wait("bell", func(c *Client) bool { return c.Bells == 1 })
+	if c.Status != "Quit." {
+		t.Errorf("status %q", c.Status)
+	}
+}
+
+func TestProtocolVersion(t *testing.T) {
+	es := NewEditorServer()
+	srv := httptest.NewServer(es.Handler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/editor"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Client{Conn: conn}
+	defer c.Close()
+	c.Send(term.Message{Type: term.MsgHello, Version: term.ProtocolVersion + 1})
+	if m, err := c.Read(); err == nil || m == nil || m.Type != term.MsgError {
+		t.Errorf("hello of another version got %v %v", m, err)
+	}
+}
@@ -2,7 +2,11 @@ package web
 
 import (
 	"context"
+	"embed"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -10,11 +14,17 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/kristofer/ke/kg"
+	"github.com/kristofer/ke/term"
 
 	"github.com/gorilla/websocket"
 )
 
+// staticFiles are the page and what it loads, built into the binary so the
+// server runs from anywhere
+//
+//go:embed static
+var staticFiles embed.FS
+
 // We'll need to define an Upgrader
 // this will require a Read and Write buffer size
 var upgrader = websocket.Upgrader{
@@ -26,60 +36,150 @@ func (editor *EditorServer) kgEditor(w http.ResponseWriter, r *http.Request) {
 
 	log.Println("running KG editor")
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	up := upgrader
+	up.CheckOrigin = editor.checkOrigin
+	conn, err := up.Upgrade(w, r, nil)
 
 	if err != nil {
 		log.Println("Nope. No websocket created. see editorserver()")
 		return
 	}
 
-	go func() {
-		editor.Editor = &kg.Editor{}
-		editor.Editor.StartEditor([]string{}, 0, conn, editor.Quit)
-
-		//editor.Quit <- syscall.SIGINT
-	}()
+	hello, err := readHello(conn)
+	if err != nil {
+		log.Println("no hello from the frontend:", err)
+		refuse(conn, err)
+		return
+	}
+	// a browser coming back gives the token of the session it had
+	s := editor.Sessions.Resume(hello.Session, conn)
+	if s == nil {
+		s = editor.Sessions.Start(conn)
+	}
+	log.Println("session", s.ID, "of", editor.Sessions.Len())
 
 	log.Println("ending KG editor")
 
 }
 
+// helloTimeout is how long a browser has to say hello once connected
+const helloTimeout = 10 * time.Second
+
+// readHello reads the hello a browser starts with, which must be for the
+// version of the protocol the editor speaks
+func readHello(conn *websocket.Conn) (*term.Message, error) {
+	conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	defer conn.SetReadDeadline(time.Time{})
+	var m term.Message
+	if err := conn.ReadJSON(&m); err != nil {
+		return nil, err
+	}
+	if m.Type != term.MsgHello {
+		return nil, fmt.Errorf("%q before hello", m.Type)
+	}
+	if m.Version != term.ProtocolVersion {
+		return nil, fmt.Errorf("protocol version %d, want %d", m.Version, term.ProtocolVersion)
+	}
+	return &m, nil
+}
+
+// refuse tells the browser on conn why it cannot have an editor, and
+// closes it
+func refuse(conn *websocket.Conn, err error) {
+	conn.WriteJSON(term.Message{Type: term.MsgError, Text: err.Error()})
+	msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "")
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	conn.Close()
+}
+
+// EditorServer serves the web frontend, with an editor session for each
+// websocket. Quit stops the server; a session ending does not.
+//
+// It listens on localhost only unless Server.Addr says otherwise. With
+// CertFile and KeyFile it serves HTTPS, with Token only to those who log in
+// with it, and websockets only from its own pages and Origins.
 type EditorServer struct {
-	Server *http.Server
-	Editor *kg.Editor
-	Quit   chan os.Signal
+	Server   *http.Server
+	Sessions *Sessions
+	Quit     chan os.Signal
+	Static   fs.FS    // the page and its files, those built in unless set
+	CertFile string   // TLS certificate, PEM
+	KeyFile  string   // and its private key
+	Token    string   // the password to log in with, "" for none
+	Origins  []string // other origins allowed to open the websocket
+	loginKey string   // the login cookie, see auth.go
 }
 
 func NewEditorServer() *EditorServer {
 	e := &EditorServer{}
 	e.Server = &http.Server{
-		Addr: ":8005",
+		Addr: "localhost:8005",
 	}
+	e.Sessions = NewSessions()
+	e.Static, _ = fs.Sub(staticFiles, "static")
 	e.Quit = make(chan os.Signal, 1)
+	e.loginKey = newSessionID()
 	return e
 }
 
-func (editor *EditorServer) StartEditorServer() {
-
-	http.HandleFunc("/editor", editor.kgEditor)
+// Handler gives the server's routes: the page, its static files and the
+// editor websocket, for those logged in, and the login page
+func (editor *EditorServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/editor", editor.kgEditor)
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		log.Println("serving main page")
 
-		http.ServeFile(w, r, "static/vt100.html")
+		editor.serveStatic(w, r, "vt100.html")
 	})
 
-	http.HandleFunc("/vt100", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/vt100", func(w http.ResponseWriter, r *http.Request) {
 		log.Println("serving main page")
 
-		http.ServeFile(w, r, "static/vt100.html")
+		editor.serveStatic(w, r, "vt100.html")
 	})
 
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(editor.Static))))
+
+	top := http.NewServeMux()
+	top.HandleFunc("/login", editor.login)
+	top.Handle("/", editor.requireLogin(mux))
+	return top
+}
+
+// serveStatic serves the file name from Static
+func (editor *EditorServer) serveStatic(w http.ResponseWriter, r *http.Request, name string) {
+	f, err := editor.Static.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	content, ok := f.(io.ReadSeeker)
+	if err != nil || !ok {
+		http.Error(w, "unable to serve "+name, http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, name, fi.ModTime(), content)
+}
+
+func (editor *EditorServer) StartEditorServer() {
+
+	editor.Server.Handler = editor.Handler()
 
 	//http.ListenAndServe(":8005", nil)
 	go func() {
-		if err := editor.Server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if editor.CertFile != "" {
+			log.Println("serving https on", editor.Server.Addr)
+			err = editor.Server.ListenAndServeTLS(editor.CertFile, editor.KeyFile)
+		} else {
+			log.Println("serving http on", editor.Server.Addr)
+			err = editor.Server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("HTTP server error: %v", err)
 		}
 		log.Println("Stopped serving new connections.")
@@ -94,6 +194,7 @@ func (editor *EditorServer) StartEditorServer() {
 	if err := editor.Server.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("HTTP shutdown error: %v", err)
 	}
+	editor.Sessions.CloseAll()
 	log.Println("Graceful shutdown complete.")
 
 }
@@ -1,9 +1,53 @@
 package web
 
-import "testing"
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
 
 func TestVT100server(t *testing.T) {
 
 	es := NewEditorServer()
 	es.StartEditorServer()
 }
+
+func TestStaticFiles(t *testing.T) {
+	// the files are built in, so the server can run from anywhere
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	srv := httptest.NewServer(NewEditorServer().Handler())
+	defer srv.Close()
+
+	for path, want := range map[string]string{
+		"/":                 "vt100.js",
+		"/vt100":            "/editor",
+		"/static/vt100.js":  "VT100",
+		"/static/style.css": ".bell",
+		"/login":            "token",
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(b), want) {
+			t.Errorf("%s got %s, without %q", path, resp.Status, want)
+		}
+	}
+	resp, err := http.Get(srv.URL + "/static/nothing.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("a missing file got %s", resp.Status)
+	}
+}
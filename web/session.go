@@ -0,0 +1,199 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kristofer/ke/kg"
+	"github.com/kristofer/ke/term"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultGrace is how long a session is kept after its browser has gone,
+// for it to come back
+const DefaultGrace = 30 * time.Minute
+
+// Session is one browser's editor. Its ID is also the token the browser
+// keeps, to get the same editor back when it reconnects.
+type Session struct {
+	ID       string
+	Editor   *kg.Editor
+	Started  time.Time
+	conn     *websocket.Conn // nil while no browser is attached
+	attachs  int             // counts browsers attached, to tell them apart
+	timer    *time.Timer     // ends the session once the grace period is up
+	ending   bool
+	quit     chan os.Signal
+	attachMu sync.Mutex // one browser attaches at a time
+}
+
+// Sessions keeps track of the editors running for browsers. A session
+// ends when its editor quits, or Grace after its browser has gone if it
+// does not come back in time.
+type Sessions struct {
+	Grace    time.Duration
+	Root     string // the directory the editors' files are kept to, "" for none
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewSessions() *Sessions {
+	return &Sessions{Grace: DefaultGrace, sessions: map[string]*Session{}}
+}
+
+// Start runs a new editor for the browser on conn
+func (ss *Sessions) Start(conn *websocket.Conn) *Session {
+	s := &Session{
+		ID:      newSessionID(),
+		Editor:  &kg.Editor{Root: ss.Root},
+		Started: time.Now(),
+		quit:    make(chan os.Signal, 1),
+	}
+	ss.mu.Lock()
+	ss.sessions[s.ID] = s
+	ss.mu.Unlock()
+	log.Println("starting session", s.ID)
+
+	s.Editor.StartSession([]string{}, s.quit)
+	go func() {
+		<-s.quit
+		ss.remove(s.ID)
+		log.Println("ended session", s.ID)
+	}()
+	ss.attach(s, conn)
+	return s
+}
+
+// Resume attaches the browser on conn to the session with token id, in
+// place of any browser still attached to it. It gives nil if there is no
+// such session, or it is ending.
+func (ss *Sessions) Resume(id string, conn *websocket.Conn) *Session {
+	s := ss.Get(id)
+	if s == nil {
+		return nil
+	}
+	log.Println("resuming session", s.ID)
+	if !ss.attach(s, conn) {
+		return nil
+	}
+	return s
+}
+
+// attach says hello to the browser on conn, with the session's token, and
+// attaches it to the editor, unless the session is ending
+func (ss *Sessions) attach(s *Session, conn *websocket.Conn) bool {
+	s.attachMu.Lock()
+	defer s.attachMu.Unlock()
+	ss.mu.Lock()
+	if s.ending {
+		ss.mu.Unlock()
+		return false
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.conn = conn
+	s.attachs++
+	n := s.attachs
+	ss.mu.Unlock()
+
+	// the editor is not writing to conn until it is attached
+	hello := term.Message{Type: term.MsgHello, Version: term.ProtocolVersion, Session: s.ID}
+	if err := conn.WriteJSON(hello); err != nil {
+		log.Println("unable to say hello", err)
+	}
+	gone := s.Editor.Attach(conn)
+	go func() {
+		<-gone
+		ss.detach(s, n)
+	}()
+	return true
+}
+
+// detach starts the grace period once the nth browser attached to s has
+// gone, if no other has taken its place
+func (ss *Sessions) detach(s *Session, n int) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if s.attachs != n || s.ending {
+		return
+	}
+	log.Println("detached session", s.ID)
+	s.conn = nil
+	s.timer = time.AfterFunc(ss.Grace, func() { ss.expire(s, n) })
+}
+
+// expire ends s if no browser has come back to it
+func (ss *Sessions) expire(s *Session, n int) {
+	ss.mu.Lock()
+	if s.attachs != n || s.ending {
+		ss.mu.Unlock()
+		return
+	}
+	s.ending = true
+	ss.mu.Unlock()
+	log.Println("session", s.ID, "was not resumed")
+	s.Editor.Stop()
+}
+
+// Get gives the session with id, or nil
+func (ss *Sessions) Get(id string) *Session {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.sessions[id]
+}
+
+// Attached tells whether a browser is attached to s
+func (ss *Sessions) Attached(s *Session) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return s.conn != nil
+}
+
+// Len gives the number of sessions running
+func (ss *Sessions) Len() int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return len(ss.sessions)
+}
+
+func (ss *Sessions) remove(id string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if s := ss.sessions[id]; s != nil {
+		s.ending = true
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+	}
+	delete(ss.sessions, id)
+}
+
+// CloseAll ends every session, closing its browser's websocket
+func (ss *Sessions) CloseAll() {
+	ss.mu.Lock()
+	all := make([]*Session, 0, len(ss.sessions))
+	for _, s := range ss.sessions {
+		s.ending = true
+		all = append(all, s)
+	}
+	ss.mu.Unlock()
+	for _, s := range all {
+		s.Editor.Stop()
+	}
+}
+
+// newSessionID gives a random id for a session
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("no random session id: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/kristofer/ke/term"
+)
+
+// dialEditor connects to the editor, resuming the session with token if
+// there is one, and reads its first screen
+func dialEditor(t *testing.T, srv *httptest.Server, token string) *Client {
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/editor"
+	c, err := Dial(url, token)
+	if err != nil {
+		t.Fatalf("dial %s: %v", url, err)
+	}
+	if c.Session == "" {
+		t.Fatalf("no session token")
+	}
+	if err := c.WaitFor(2*time.Second, func(c *Client) bool { return c.Rows > 0 }); err != nil {
+		t.Fatalf("no first screen: %v", err)
+	}
+	return c
+}
+
+func waitSessions(t *testing.T, ss *Sessions, n int) {
+	for i := 0; i < 100 && ss.Len() != n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ss.Len() != n {
+		t.Fatalf("%d sessions, want %d", ss.Len(), n)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	es := NewEditorServer()
+	es.Sessions.Grace = 10 * time.Millisecond
+	srv := httptest.NewServer(es.Handler())
+	defer srv.Close()
+
+	a := dialEditor(t, srv, "")
+	b := dialEditor(t, srv, "")
+	waitSessions(t, es.Sessions, 2)
+
+	// C-q in one editor ends only that session
+	if err := a.Key("q", term.ModCtrl); err != nil {
+		t.Fatal(err)
+	}
+	waitSessions(t, es.Sessions, 1)
+	never := func(*Client) bool { return false }
+	if err := a.WaitFor(2*time.Second, never); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("quit session closed with %v", err)
+	}
+
+	if err := b.Type("x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WaitFor(2*time.Second, func(c *Client) bool { return strings.HasPrefix(c.Line(0), "x") }); err != nil {
+		t.Errorf("other session after a quit: %v\n%s", err, b.Screen())
+	}
+
+	// closing the websocket ends the session too, once it is not resumed
+	b.Close()
+	waitSessions(t, es.Sessions, 0)
+}
+
+func TestSessionIDs(t *testing.T) {
+	if a, b := newSessionID(), newSessionID(); a == b || len(a) != 32 {
+		t.Errorf("session ids %q and %q", a, b)
+	}
+}
+
+func TestSessionClosedAtPrompt(t *testing.T) {
+	es := NewEditorServer()
+	es.Sessions.Grace = 10 * time.Millisecond
+	srv := httptest.NewServer(es.Handler())
+	defer srv.Close()
+
+	c := dialEditor(t, srv, "")
+	// C-x C-f waits in the minibuffer for a file name
+	c.Key("x", term.ModCtrl)
+	c.Key("f", term.ModCtrl)
+	if err := c.WaitFor(2*time.Second, func(c *Client) bool { return strings.HasPrefix(c.Line(c.Rows-1), "Find file") }); err != nil {
+		t.Errorf("no prompt: %v\n%s", err, c.Screen())
+	}
+	c.Close()
+	waitSessions(t, es.Sessions, 0)
+}
+
+func TestSessionResume(t *testing.T) {
+	es := NewEditorServer()
+	srv := httptest.NewServer(es.Handler())
+	defer srv.Close()
+
+	c := dialEditor(t, srv, "")
+	token := c.Session
+	c.Type("x")
+	c.WaitFor(2*time.Second, func(c *Client) bool { return strings.HasPrefix(c.Line(0), "x") })
+	c.Conn.Close()
+	s := es.Sessions.Get(token)
+	for i := 0; i < 100 && es.Sessions.Attached(s); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s == nil || es.Sessions.Attached(s) {
+		t.Fatalf("session not kept detached")
+	}
+
+	// coming back gets the same editor, and the whole screen again
+	c = dialEditor(t, srv, token)
+	defer c.Close()
+	if c.Session != token || es.Sessions.Len() != 1 {
+		t.Errorf("resumed session %q of %d, want %q", c.Session, es.Sessions.Len(), token)
+	}
+	if !strings.HasPrefix(c.Line(0), "x1 foo") {
+		t.Errorf("resumed screen\n%s", c.Screen())
+	}
+
+	// an unknown token gets a new session
+	other := dialEditor(t, srv, "nope")
+	defer other.Close()
+	if other.Session == token || other.Session == "nope" || es.Sessions.Len() != 2 {
+		t.Errorf("unknown token got session %q of %d", other.Session, es.Sessions.Len())
+	}
+}
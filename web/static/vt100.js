@@ -5,6 +5,10 @@
 // Released under the GNU LGPL v2.1, by Frank Bi <bi@zompower.tk>
 //Added to by Kristofer Younger <kris atz zipcodewilmington dot com>
 // 2023-06-01 - upgraded to osrta latest JS
+//		- drawFrame() draws a whole screen sent as a JSON frame,
+//		  with ANSI colours (VT100.ANSI_BASE + n) and attributes
+//		- resize() and fits() for a terminal the size of the window
+//		- drawDamage() draws only what changed since the last frame
 // 2007-08-12	- refresh():
 //		  - factor out colour code to html_colours_()
 //		  - fix handling of A_REVERSE | A_DIM
@@ -74,6 +78,17 @@
 //	write(stuff)	Writes `stuff' to the terminal and immediately
 //			updates the display; (some) escape sequences are
 //			interpreted and acted on.
+//	drawFrame(f)	Replaces the whole display with frame `f' -- its
+//			`text' (all the rows one after another) and the
+//			`runs' of it drawn in colour -- and updates it.
+//	drawDamage(d)	Draws the `spans' of the screen that changed, each
+//			a `text' at a `row' and `col' with its own `runs',
+//			moves the cursor and updates the display.
+//	fits()		Returns an associative array with the columns
+//			(`cols') and rows (`rows') of characters there is
+//			room for in the window.
+//	resize(wd, ht)	Makes the terminal `wd' wide and `ht' high, keeping
+//			what fits of the old contents.
 
 // constructor
 function VT100(wd, ht, scr_id) {
@@ -128,6 +143,14 @@ VT100.COLOR_YELLOW = 6;
 VT100.COLOR_WHITE = 7;
 VT100.COLOR_PAIRS = 256;
 VT100.COLORS = 8;
+// ANSI colours 0-15 from frames are kept as VT100.ANSI_BASE + n
+VT100.ANSI_BASE = 100;
+VT100.PALETTE_ = [
+    '#000000', '#B00000', '#006400', '#FFF926',
+    '#0000B0', '#800080', '#005F5F', '#FFFFFF',
+    '#404040', '#FF3030', '#00A000', '#FFFF60',
+    '#3030FF', '#C000C0', '#008080', '#FFFFFF'
+];
 // public constants -- attributes
 VT100.A_NORMAL = 0;
 VT100.A_UNDERLINE = 1;
@@ -259,9 +282,18 @@ VT100.prototype.may_scroll_ = function() {
 }
 
 VT100.prototype.html_colours_ = function(attr) {
-    var fg, bg, co0, co1;
+    var fg, bg, co0, co1, f, b;
     fg = attr.fg;
     bg = attr.bg;
+    // ANSI colours from a frame, on Kris colors where they are not set
+    if (fg >= VT100.ANSI_BASE || bg >= VT100.ANSI_BASE) {
+        f = fg >= VT100.ANSI_BASE ? VT100.PALETTE_[fg - VT100.ANSI_BASE] : '#FFF926';
+        b = bg >= VT100.ANSI_BASE ? VT100.PALETTE_[bg - VT100.ANSI_BASE] : '#AEAEAE';
+        if (attr.mode & VT100.A_REVERSE) {
+            return { f: b, b: f };
+        }
+        return { f: f, b: b };
+    }
     // is we are using Kris colors, and reverse, it's the cursor!
     if (((bg == VT100.KY_BACK) || (bg == VT100.KY_FORE)) && (attr.mode & VT100.A_REVERSE)) {
         return {
@@ -421,6 +453,61 @@ VT100.prototype.clear = function() {
     }
 }
 
+VT100.prototype.resize = function(wd, ht) {
+    var r, c, text = new Array(ht), attr = new Array(ht);
+    for (r = 0; r < ht; ++r) {
+        text[r] = new Array(wd);
+        attr[r] = new Array(wd);
+        for (c = 0; c < wd; ++c) {
+            if (r < this.ht_ && c < this.wd_) {
+                text[r][c] = this.text_[r][c];
+                attr[r][c] = this.attr_[r][c];
+            } else {
+                text[r][c] = ' ';
+                attr[r][c] = this._cloneAttr(this.bkgd_);
+            }
+        }
+    }
+    this.text_ = text;
+    this.attr_ = attr;
+    this.wd_ = wd;
+    this.ht_ = ht;
+    this.cursor_col = Math.min(this.cursor_col, wd - 1);
+    this.cursor_row = Math.min(this.cursor_row, ht - 1);
+    this.refresh();
+}
+
+// charSize_() is the width and height of a character in the terminal's font
+VT100.prototype.charSize_ = function() {
+    var probe = document.createElement("span"),
+        box;
+    probe.textContent = "MMMMMMMMMM";
+    probe.style.visibility = "hidden";
+    this.scr_.appendChild(probe);
+    box = probe.getBoundingClientRect();
+    this.scr_.removeChild(probe);
+    return { width: box.width / 10, height: box.height };
+}
+
+VT100.prototype.fits = function() {
+    var ch = this.charSize_(),
+        cols, rows;
+    cols = Math.floor(this.scr_.clientWidth / ch.width);
+    rows = Math.floor((window.innerHeight - this.scr_.getBoundingClientRect().top) / ch.height) - 1;
+    return { cols: Math.max(cols, 20), rows: Math.max(rows, 5) };
+}
+
+// cellAt(x, y) is the column and row of the screen at a point of the page,
+// as a mouse event gives it
+VT100.prototype.cellAt = function(x, y) {
+    var ch = this.charSize_(),
+        box = this.scr_.getBoundingClientRect();
+    return {
+        col: Math.min(Math.max(Math.floor((x - box.left) / ch.width), 0), this.wd_ - 1),
+        row: Math.min(Math.max(Math.floor((y - box.top) / ch.height), 0), this.ht_ - 1)
+    };
+}
+
 VT100.prototype.clrtobot = function() {
     this.debug("clrtobot, row: " + this.row_);
     var ht = this.ht_;
@@ -884,6 +971,142 @@ VT100.prototype.write = function(stuff) {
     this.refresh();
 }
 
+// frame attribute bits, as in term.Frame*
+VT100.FRAME_ATTRS_ = [
+    [1, VT100.A_BOLD],
+    [2, VT100.A_UNDERLINE],
+    [4, VT100.A_REVERSE],
+    [8, VT100.A_BLINK],
+    [16, VT100.A_DIM]
+];
+
+// frameAttr_(run) is the attribute to draw a run of a frame or span in
+VT100.prototype.frameAttr_ = function(run) {
+    var attr = {
+        mode: VT100.A_NORMAL,
+        fg: run.fg < 0 ? this.bkgd_.fg : VT100.ANSI_BASE + run.fg,
+        bg: run.bg < 0 ? this.bkgd_.bg : VT100.ANSI_BASE + run.bg
+    };
+    VT100.FRAME_ATTRS_.forEach(function(fa) {
+        if (run.attr & fa[0])
+            attr.mode |= fa[1];
+    });
+    return attr;
+}
+
+VT100.prototype.drawFrame = function(frame) {
+    var r, c, i, chars = Array.from(frame.text);
+    if (frame.cols != this.wd_ || frame.rows != this.ht_)
+        this.resize(frame.cols, frame.rows);
+    for (r = 0; r < this.ht_; ++r) {
+        for (c = 0; c < this.wd_; ++c) {
+            i = r * frame.cols + c;
+            if (r < frame.rows && c < frame.cols && i < chars.length)
+                this.text_[r][c] = chars[i];
+            else
+                this.text_[r][c] = ' ';
+            this.attr_[r][c] = this.bkgd_;
+        }
+    }
+    frame.runs.forEach(function(run) {
+        var attr = this.frameAttr_(run);
+        for (i = run.at; i < run.at + run.n; ++i) {
+            r = Math.floor(i / frame.cols);
+            c = i % frame.cols;
+            if (r < this.ht_ && c < this.wd_)
+                this.attr_[r][c] = attr;
+        }
+    }, this);
+    if (frame.cursor)
+        this.move(frame.cursor[1], frame.cursor[0]);
+    this.refresh();
+}
+
+VT100.prototype.drawDamage = function(damage) {
+    if (damage.cols != this.wd_ || damage.rows != this.ht_)
+        this.resize(damage.cols, damage.rows);
+    damage.spans.forEach(function(span) {
+        var c, i, chars = Array.from(span.text);
+        if (span.row >= this.ht_)
+            return;
+        for (i = 0; i < chars.length; ++i) {
+            c = span.col + i;
+            if (c < this.wd_) {
+                this.text_[span.row][c] = chars[i];
+                this.attr_[span.row][c] = this.bkgd_;
+            }
+        }
+        span.runs.forEach(function(run) {
+            var attr = this.frameAttr_(run);
+            for (i = run.at; i < run.at + run.n; ++i) {
+                c = span.col + i;
+                if (c < this.wd_)
+                    this.attr_[span.row][c] = attr;
+            }
+        }, this);
+    }, this);
+    this.move(damage.cursor[1], damage.cursor[0]);
+    this.refresh();
+}
+
+// receive(msg) shows a message from the editor: see term/protocol.go
+VT100.prototype.receive = function(msg) {
+    switch (msg.type) {
+        case "frame":
+            // the whole screen, with colours
+            this.drawFrame(msg);
+            break;
+        case "diff":
+            // what changed since the last frame
+            this.drawDamage(msg);
+            break;
+        case "cursor":
+            this.move(msg.cursor[1], msg.cursor[0]);
+            this.refresh();
+            break;
+        case "title":
+            document.title = msg.text || "kg";
+            break;
+        case "status":
+            if (this.onstatus)
+                this.onstatus(msg.text || "");
+            break;
+        case "bell":
+            this.bell();
+            break;
+        default:
+            this.warn("unknown message " + msg.type);
+    }
+}
+
+// bell() flashes the screen
+VT100.prototype.bell = function() {
+    var scr = this.scr_;
+    scr.classList.add("bell");
+    setTimeout(function() {
+        scr.classList.remove("bell");
+    }, 100);
+}
+
+// KeyMessage(e) is the key message for a keydown event, or null for a key
+// the editor has no use for: a modifier on its own, or one the browser
+// keeps, with Meta (Cmd) held down
+VT100.KeyMessage = function(e) {
+    if (e.metaKey || e.isComposing)
+        return null;
+    switch (e.key) {
+        case "Shift":
+        case "Control":
+        case "Alt":
+        case "Meta":
+        case "CapsLock":
+        case "Dead":
+        case "Unidentified":
+            return null;
+    }
+    return { type: "key", key: e.key, ctrl: e.ctrlKey, alt: e.altKey, shift: e.shiftKey };
+}
+
 VT100.prototype.debug = function(message) {
     if (this.debug_) {
         console.log(message + "\n");